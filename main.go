@@ -17,10 +17,14 @@ limitations under the License.
 package main
 
 import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
 	"github.com/paypal/load-watcher/pkg/watcher"
 	"github.com/paypal/load-watcher/pkg/watcher/api"
 	log "github.com/sirupsen/logrus"
-	"os"
 )
 
 func init() {
@@ -36,6 +40,9 @@ func init() {
 }
 
 func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	client, err := api.NewLibraryClient(watcher.EnvMetricProviderOpts)
 	if err != nil {
 		log.Fatalf("unable to create client: %v", err)
@@ -46,6 +53,7 @@ func main() {
 	}
 	log.Debugf("received metrics: %v", metrics)
 
-	// Keep the watcher server up
-	select {}
+	// Keep the watcher server up until terminated
+	<-ctx.Done()
+	client.Stop()
 }
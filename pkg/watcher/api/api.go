@@ -16,10 +16,34 @@ limitations under the License.
 
 package api
 
-import "github.com/paypal/load-watcher/pkg/watcher"
+import (
+	"context"
+	"errors"
 
-// Watcher Client API
+	"github.com/paypal/load-watcher/pkg/watcher"
+)
+
+// ErrNoMetrics Returned when a query selects hosts and none of them has metrics
+var ErrNoMetrics = errors.New("no metrics found")
+
+// Watcher Client API. Library and service clients behave the same: stale metrics are returned along with
+// watcher.ErrStaleMetrics, and queries selecting hosts without metrics fail with ErrNoMetrics
 type Client interface {
 	// Returns latest metrics present in load Watcher cache
 	GetLatestWatcherMetrics() (*watcher.WatcherMetrics, error)
+	// Returns latest metrics present in load Watcher cache, giving up once ctx is done
+	GetLatestWatcherMetricsContext(ctx context.Context) (*watcher.WatcherMetrics, error)
+	// Returns the latest metrics of the query window, with only the hosts and metrics selected by the query.
+	// The default window of the Watcher is used if the query has none
+	GetWatcherMetrics(ctx context.Context, query watcher.MetricsQuery) (*watcher.WatcherMetrics, error)
+	// Returns the cached metrics of the query window fetched at or after since (unix seconds), oldest first,
+	// with only the hosts and metrics selected by the query
+	GetWatcherMetricsHistory(ctx context.Context, query watcher.MetricsQuery, since int64) ([]watcher.WatcherMetrics, error)
+}
+
+// Watcher Client API when using watcher as a library, which owns a running Watcher
+type LibraryClient interface {
+	Client
+	// Stops the Watcher and shuts down its server
+	Stop()
 }
@@ -0,0 +1,135 @@
+/*
+Copyright 2021 PayPal
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Number of requests whose responses are cached, the least recently stored are evicted first
+	maxCachedResponses = 256
+)
+
+// A response of the watcher, with its validators for conditional requests
+type cachedResponse struct {
+	// Body of a response with status 200, or 503 for stale metrics
+	body         []byte
+	contentType  string
+	etag         string
+	lastModified string
+	// Duration the watcher deems the response fresh for, from its Cache-Control header
+	maxAge time.Duration
+	// Time until which the response is served without requesting the watcher
+	expires time.Time
+	stored  time.Time
+}
+
+// Updates the validators and freshness of the response from the headers of a response of the watcher, when
+// it answered a conditional request with Not Modified
+func (r *cachedResponse) update(header http.Header) {
+	if etag := header.Get("ETag"); etag != "" {
+		r.etag = etag
+	}
+	if lastModified := header.Get("Last-Modified"); lastModified != "" {
+		r.lastModified = lastModified
+	}
+	r.maxAge = parseMaxAge(header.Get("Cache-Control"))
+}
+
+// Sets the validators of the response as conditions of a request
+func (r *cachedResponse) setConditions(req *http.Request) {
+	if r.etag != "" {
+		req.Header.Set("If-None-Match", r.etag)
+	}
+	if r.lastModified != "" {
+		req.Header.Set("If-Modified-Since", r.lastModified)
+	}
+}
+
+// Caches the latest response of every request. Responses are served while fresh, for the TTL of the cache, the max-age
+// of the response if zero, or never if negative, and revalidated with conditional requests if they have validators
+type responseCache struct {
+	ttl time.Duration
+
+	mutex   sync.Mutex
+	entries map[string]*cachedResponse
+}
+
+func newResponseCache(ttl time.Duration) *responseCache {
+	return &responseCache{ttl: ttl, entries: make(map[string]*cachedResponse)}
+}
+
+// Returns a copy of the cached response of a request, nil if none
+func (c *responseCache) get(key string) *cachedResponse {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	response := *entry
+	return &response
+}
+
+// Stores the response of a request, unless it can neither be served nor revalidated
+func (c *responseCache) put(key string, response cachedResponse) {
+	ttl := c.ttl
+	if ttl == 0 {
+		ttl = response.maxAge
+	}
+	if ttl <= 0 && response.etag == "" && response.lastModified == "" {
+		return
+	}
+	now := time.Now()
+	response.expires = now.Add(ttl)
+	response.stored = now
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= maxCachedResponses {
+		var oldest string
+		for k, entry := range c.entries {
+			if oldest == "" || entry.stored.Before(c.entries[oldest].stored) {
+				oldest = k
+			}
+		}
+		delete(c.entries, oldest)
+	}
+	c.entries[key] = &response
+}
+
+// Returns the max-age of a Cache-Control header, zero if it has none or requires revalidation
+func parseMaxAge(cacheControl string) time.Duration {
+	var maxAge time.Duration
+	for _, directive := range strings.Split(cacheControl, ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		if directive == "no-cache" || directive == "no-store" {
+			return 0
+		}
+		if value, ok := strings.CutPrefix(directive, "max-age="); ok {
+			if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+				maxAge = time.Duration(seconds) * time.Second
+			}
+		}
+	}
+	return maxAge
+}
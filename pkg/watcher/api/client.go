@@ -17,11 +17,21 @@ limitations under the License.
 package api
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/francoispqt/gojay"
+	"github.com/klauspost/compress/zstd"
 	"github.com/paypal/load-watcher/pkg/watcher"
 	"github.com/paypal/load-watcher/pkg/watcher/internal/metricsprovider"
 
@@ -30,86 +40,401 @@ import (
 
 const (
 	httpClientTimeoutSeconds = 55 * time.Second
+	// Namespace of the pod, mounted with its service account token
+	serviceAccountNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
 )
 
 // Client for Watcher APIs as a library
 type libraryClient struct {
 	fetcherClient watcher.MetricsProviderClient
 	watcher       *watcher.Watcher
+	// Closed on Stop, as the client created them
+	closers []io.Closer
 }
 
 // Client for Watcher APIs as a service
 type serviceClient struct {
-	httpClient     http.Client
-	watcherAddress string
+	httpClient  http.Client
+	endpoints   *endpointBalancer
+	cache       *responseCache
+	retryPolicy watcher.RetryPolicy
+	breaker     *watcher.CircuitBreaker
+}
+
+// ServiceClientOpts Selects the watcher replicas of a service client, and how long it caches their responses
+type ServiceClientOpts struct {
+	// Base URLs of the watcher replicas, e.g. http://load-watcher-0.load-watcher:2020
+	Addresses []string
+	// Kubernetes Service of the watcher replicas, as name.namespace or name in the namespace of the client pod,
+	// resolved to the addresses of its ready endpoints. Used instead of Addresses if set
+	Service string
+	// Name or number of the endpoint port of Service, its first port if empty
+	ServicePort string
+	// Scheme of the endpoints of Service, http if empty
+	ServiceScheme string
+	// Duration responses are served from the cache of the client without requesting a replica, as long as the
+	// watcher deems them fresh if zero. If negative, every call requests a replica, revalidating cached responses
+	CacheTTL time.Duration
 }
 
 // Creates a new watcher client when using watcher as a library
-func NewLibraryClient(opts watcher.MetricsProviderOpts) (Client, error) {
+func NewLibraryClient(opts watcher.MetricsProviderOpts) (LibraryClient, error) {
 	var err error
 	client := libraryClient{}
+	watcherOpts := watcher.EnvWatcherOpts
+	client.fetcherClient, err = newMetricsProviderClient(opts, watcherOpts.Windows)
+	if err != nil {
+		return nil, err
+	}
+	if closer, ok := client.fetcherClient.(io.Closer); ok {
+		client.closers = append(client.closers, closer)
+	}
+	if watcher.EnvNodeTaggerOpts.Enabled {
+		if watcherOpts.NodeTagger, err = metricsprovider.NewKubernetesNodeTagger(watcher.EnvNodeTaggerOpts); err != nil {
+			client.close()
+			return nil, err
+		}
+		if closer, ok := watcherOpts.NodeTagger.(io.Closer); ok {
+			client.closers = append(client.closers, closer)
+		}
+	}
+	client.watcher = watcher.NewWatcher(client.fetcherClient, watcherOpts)
+	if err = client.watcher.Start(context.Background()); err != nil {
+		client.close()
+		return nil, err
+	}
+	return client, nil
+}
+
+// Creates the metrics provider client named by opts, the Kubernetes Metrics Server client by default, for a Watcher
+// of windows
+func newMetricsProviderClient(opts watcher.MetricsProviderOpts, windows []time.Duration) (watcher.MetricsProviderClient, error) {
 	switch opts.Name {
 	case watcher.PromClientName:
-		client.fetcherClient, err = metricsprovider.NewPromClient(opts)
+		return metricsprovider.NewPromClient(opts)
 	case watcher.SignalFxClientName:
-		client.fetcherClient, err = metricsprovider.NewSignalFxClient(opts)
+		return metricsprovider.NewSignalFxClient(opts)
 	case watcher.DatadogClientName:
-		client.fetcherClient, err = metricsprovider.NewDatadogClient(opts)
+		return metricsprovider.NewDatadogClient(opts)
+	case watcher.CompositeClientName, watcher.FailoverClientName:
+		clients := make([]watcher.MetricsProviderClient, 0, len(opts.Providers))
+		for _, providerOpts := range opts.Providers {
+			providerClient, err := newMetricsProviderClient(providerOpts, windows)
+			if err != nil {
+				return nil, fmt.Errorf("unable to create %v client: %w", providerOpts.Name, err)
+			}
+			clients = append(clients, providerClient)
+		}
+		if opts.Name == watcher.FailoverClientName {
+			return metricsprovider.NewFailoverClient(opts.RecoveryPeriod, clients...)
+		}
+		return metricsprovider.NewCompositeClient(clients...)
 	default:
-		client.fetcherClient, err = metricsprovider.NewMetricsServerClient()
-	}
-	if err != nil {
-		return client, err
+		return metricsprovider.NewMetricsServerClient(windows)
 	}
-	client.watcher = watcher.NewWatcher(client.fetcherClient)
-	client.watcher.StartWatching()
-	return client, nil
 }
 
 // Creates a new watcher client when using watcher as a service
 func NewServiceClient(watcherAddress string) (Client, error) {
+	return NewServiceClientWithOpts(ServiceClientOpts{Addresses: []string{watcherAddress}})
+}
+
+// Creates a new watcher client of several watcher replicas, or of the replicas behind a Kubernetes Service.
+// Requests are spread over the replicas, and fail over to the next replica when one is unavailable
+func NewServiceClientWithOpts(opts ServiceClientOpts) (Client, error) {
+	var resolver watcher.EndpointResolver = staticResolver(opts.Addresses)
+	if opts.Service != "" {
+		name, namespace, ok := strings.Cut(opts.Service, ".")
+		if !ok {
+			namespace = podNamespace()
+		}
+		var err error
+		if resolver, err = metricsprovider.NewKubernetesServiceResolver(namespace, name, opts.ServicePort, opts.ServiceScheme); err != nil {
+			return nil, err
+		}
+	} else if len(opts.Addresses) == 0 {
+		return nil, errors.New("service client needs a watcher address or Kubernetes Service")
+	}
+	return newServiceClient(resolver, opts.CacheTTL), nil
+}
+
+func newServiceClient(resolver watcher.EndpointResolver, cacheTTL time.Duration) serviceClient {
 	return serviceClient{
 		httpClient: http.Client{
 			Timeout: httpClientTimeoutSeconds,
 		},
-		watcherAddress: watcherAddress,
-	}, nil
+		endpoints:   newEndpointBalancer(resolver),
+		cache:       newResponseCache(cacheTTL),
+		retryPolicy: watcher.DefaultRetryPolicy,
+		breaker:     watcher.NewCircuitBreaker(watcher.DefaultCircuitBreakerPolicy),
+	}
+}
+
+// Returns the namespace of the pod the client runs in, default outside of Kubernetes
+func podNamespace() string {
+	if namespace, err := os.ReadFile(serviceAccountNamespaceFile); err == nil {
+		return strings.TrimSpace(string(namespace))
+	}
+	return "default"
 }
 
 func (c libraryClient) GetLatestWatcherMetrics() (*watcher.WatcherMetrics, error) {
-	return c.watcher.GetLatestWatcherMetrics(watcher.FifteenMinutes)
+	return c.GetLatestWatcherMetricsContext(context.Background())
+}
+
+// GetLatestWatcherMetricsContext Metrics of the default window of the Watcher are read from its cache, which does
+// not block, as the service client gets them
+func (c libraryClient) GetLatestWatcherMetricsContext(ctx context.Context) (*watcher.WatcherMetrics, error) {
+	return c.GetWatcherMetrics(ctx, watcher.MetricsQuery{})
+}
+
+func (c libraryClient) GetWatcherMetrics(ctx context.Context, query watcher.MetricsQuery) (*watcher.WatcherMetrics, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	metrics, err := c.watcher.GetWatcherMetrics(query)
+	if metrics == nil {
+		return nil, err
+	}
+	if query.SelectsNodes() && len(metrics.Data.NodeMetricsMap) == 0 {
+		return nil, ErrNoMetrics
+	}
+	return metrics, err
+}
+
+func (c libraryClient) GetWatcherMetricsHistory(ctx context.Context, query watcher.MetricsQuery, since int64) ([]watcher.WatcherMetrics, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.watcher.QueryWatcherMetricsHistory(query, since)
+}
+
+// Stop Stops the Watcher, and the background work of the metrics provider client and node tagger, such as informers
+func (c libraryClient) Stop() {
+	c.watcher.Stop()
+	c.close()
+}
+
+func (c libraryClient) close() {
+	for _, closer := range c.closers {
+		if err := closer.Close(); err != nil {
+			klog.Errorf("unable to close watcher client: %v", err)
+		}
+	}
 }
 
 func (c serviceClient) GetLatestWatcherMetrics() (*watcher.WatcherMetrics, error) {
-	req, err := http.NewRequest(http.MethodGet, c.watcherAddress+watcher.BaseUrl, nil)
+	return c.GetLatestWatcherMetricsContext(context.Background())
+}
+
+func (c serviceClient) GetLatestWatcherMetricsContext(ctx context.Context) (*watcher.WatcherMetrics, error) {
+	return c.GetWatcherMetrics(ctx, watcher.MetricsQuery{})
+}
+
+func (c serviceClient) GetWatcherMetrics(ctx context.Context, query watcher.MetricsQuery) (*watcher.WatcherMetrics, error) {
+	var metrics *watcher.WatcherMetrics
+	err := c.get(ctx, watcher.BaseUrl, query.Values(), func(body []byte, contentType string) (bool, error) {
+		metrics = &watcher.WatcherMetrics{Data: watcher.Data{NodeMetricsMap: make(map[string]watcher.NodeMetrics)}}
+		var err error
+		if contentType == watcher.ProtobufContentType {
+			err = metrics.UnmarshalProtobuf(body)
+		} else {
+			err = decodeJSON(body, metrics)
+		}
+		return metrics.Stale, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if metrics.Stale {
+		return metrics, watcher.ErrStaleMetrics
+	}
+	return metrics, nil
+}
+
+func (c serviceClient) GetWatcherMetricsHistory(ctx context.Context, query watcher.MetricsQuery, since int64) ([]watcher.WatcherMetrics, error) {
+	values := query.Values()
+	if since != 0 {
+		values.Set(watcher.SinceParam, strconv.FormatInt(since, 10))
+	}
+	var history watcher.WatcherMetricsList
+	err := c.get(ctx, watcher.HistoryUrl, values, func(body []byte, contentType string) (bool, error) {
+		history = nil
+		return false, decodeJSON(body, &history)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// Gets path of the watcher with the query values, and decodes the response with decode, which returns true if it
+// decoded stale metrics. Stale metrics are served with status 503, and are not an error here. Fresh cached responses
+// are decoded without requesting the watcher. Otherwise the replicas are requested in turn until one responds,
+// retrying retryable errors
+func (c serviceClient) get(ctx context.Context, path string, values url.Values, decode bodyDecoder) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	key := path + "?" + values.Encode()
+	cached := c.cache.get(key)
+	if cached != nil && time.Now().Before(cached.expires) {
+		_, err := decodeBody(cached.body, cached.contentType, decode)
+		return err
+	}
+
+	var clientErr error
+	err := c.retryPolicy.Do(ctx, c.breaker, func() error {
+		response, err := c.getFromReplicas(ctx, path, values, cached, decode)
+		switch {
+		case err == nil:
+			c.cache.put(key, *response)
+		case isClientError(err):
+			// The replica is up, and would answer retries alike
+			clientErr = err
+			return nil
+		case watcher.IsRetryable(err):
+			klog.Warningf("retryable error while getting watcher metrics: %v", err)
+		}
+		return err
+	})
+	if err != nil {
+		klog.Error(err)
+		return err
+	}
+	return clientErr
+}
+
+// Returns true if err is the answer of a replica to a request it can't serve, rather than a failure of the replica
+func isClientError(err error) bool {
+	var statusErr *watcher.HTTPStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 400 && statusErr.StatusCode < 500 && statusErr.StatusCode != http.StatusTooManyRequests
+	}
+	return errors.Is(err, ErrNoMetrics)
+}
+
+// Requests the replicas in turn until one responds, or fails with a permanent error
+func (c serviceClient) getFromReplicas(ctx context.Context, path string, values url.Values, cached *cachedResponse, decode bodyDecoder) (*cachedResponse, error) {
+	endpoints, err := c.endpoints.endpoints()
+	if err != nil {
+		return nil, err
+	}
+	var errs []error
+	for _, endpoint := range endpoints {
+		response, err := c.getOnce(ctx, endpoint, path, values, cached, decode)
+		if err == nil || !watcher.IsRetryable(err) {
+			// Replicas which respond without metrics are up
+			c.endpoints.record(endpoint, false)
+			return response, err
+		}
+		c.endpoints.record(endpoint, true)
+		errs = append(errs, fmt.Errorf("%v: %w", endpoint, err))
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, errors.Join(errs...)
+}
+
+func (c serviceClient) getOnce(ctx context.Context, endpoint string, path string, values url.Values, cached *cachedResponse, decode bodyDecoder) (*cachedResponse, error) {
+	uri := endpoint + path
+	if len(values) > 0 {
+		uri += "?" + values.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", watcher.AcceptProtobuf)
+	req.Header.Set("Accept-Encoding", watcher.AcceptEncoding)
+	if cached != nil {
+		cached.setConditions(req)
+	}
 
-	//TODO(aqadeer): Add a couple of retries for transient errors
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
-	klog.V(6).Infof("received status code %v from watcher", resp.StatusCode)
-	if resp.StatusCode == http.StatusOK {
-		data := watcher.Data{NodeMetricsMap: make(map[string]watcher.NodeMetrics)}
-		metrics := watcher.WatcherMetrics{Data: data}
-		dec := gojay.BorrowDecoder(resp.Body)
-		defer dec.Release()
-		err = dec.Decode(&metrics)
-		if err != nil {
-			klog.Errorf("unable to decode watcher metrics: %v", err)
+	klog.V(6).Infof("received status code %v from watcher %v", resp.StatusCode, endpoint)
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cached != nil:
+		if _, err = decodeBody(cached.body, cached.contentType, decode); err != nil {
 			return nil, err
-		} else {
-			return &metrics, nil
 		}
-	} else {
-		err = fmt.Errorf("received status code %v from watcher", resp.StatusCode)
-		klog.Error(err)
+		cached.update(resp.Header)
+		return cached, nil
+	case resp.StatusCode == http.StatusNotFound:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		if len(body) == 0 {
+			return nil, ErrNoMetrics
+		}
+		return nil, fmt.Errorf("%w: %s", ErrNoMetrics, body)
+	case resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusServiceUnavailable:
+		return nil, watcher.NewHTTPStatusError(resp)
+	}
+
+	body, err := readBody(resp)
+	if err != nil {
 		return nil, err
 	}
-	return nil, nil
+	contentType := resp.Header.Get("Content-Type")
+	stale, err := decodeBody(body, contentType, decode)
+	if resp.StatusCode == http.StatusServiceUnavailable && (err != nil || !stale) {
+		// Unavailable, rather than serving stale metrics
+		return nil, watcher.NewHTTPStatusError(resp)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &cachedResponse{
+		body:         body,
+		contentType:  contentType,
+		etag:         resp.Header.Get("ETag"),
+		lastModified: resp.Header.Get("Last-Modified"),
+		maxAge:       parseMaxAge(resp.Header.Get("Cache-Control")),
+	}, nil
+}
+
+// Reads the body of a response, decompressing it as per its Content-Encoding
+func readBody(resp *http.Response) ([]byte, error) {
+	switch encoding := resp.Header.Get("Content-Encoding"); encoding {
+	case "", "identity":
+		return io.ReadAll(resp.Body)
+	case watcher.GzipEncoding:
+		reader, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+		return io.ReadAll(reader)
+	case watcher.ZstdEncoding:
+		reader, err := zstd.NewReader(resp.Body, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+		return io.ReadAll(reader)
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %s", encoding)
+	}
+}
+
+// Decodes the body of a response of the watcher with a content type, returning true if it decoded stale metrics
+type bodyDecoder func(body []byte, contentType string) (bool, error)
+
+func decodeBody(body []byte, contentType string, decode bodyDecoder) (bool, error) {
+	stale, err := decode(body, contentType)
+	if err != nil {
+		return false, fmt.Errorf("unable to decode watcher metrics: %w", err)
+	}
+	return stale, nil
+}
+
+func decodeJSON(body []byte, v interface{}) error {
+	dec := gojay.BorrowDecoder(bytes.NewReader(body))
+	defer dec.Release()
+	return dec.Decode(v)
 }
@@ -0,0 +1,234 @@
+/*
+Copyright 2021 PayPal
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/paypal/load-watcher/pkg/watcher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestWatcher(t *testing.T, windows ...time.Duration) *watcher.Watcher {
+	w := watcher.NewWatcher(watcher.NewTestMetricsServerClient(), watcher.WatcherOpts{DisableServer: true, Windows: windows})
+	require.Nil(t, w.Start(context.Background()))
+	t.Cleanup(w.Stop)
+	return w
+}
+
+// Returns a library client and a service client of the same started Watcher of windows, the default ones if none
+func newTestClients(t *testing.T, windows ...time.Duration) (Client, Client) {
+	w := newTestWatcher(t, windows...)
+	server := httptest.NewServer(w.Handler())
+	t.Cleanup(server.Close)
+	service, err := NewServiceClient(server.URL)
+	require.Nil(t, err)
+	return libraryClient{watcher: w}, service
+}
+
+func TestClientsBehaveTheSame(t *testing.T) {
+	library, service := newTestClients(t)
+	ctx := context.Background()
+
+	for _, query := range []watcher.MetricsQuery{
+		{},
+		{Window: watcher.TenMinutes},
+		{Hosts: []string{watcher.FirstNode}, Types: []string{watcher.CPU}},
+		{Window: watcher.FiveMinutes, HostsPattern: "worker-"},
+	} {
+		expected, err := library.GetWatcherMetrics(ctx, query)
+		require.Nil(t, err)
+		metrics, err := service.GetWatcherMetrics(ctx, query)
+		require.Nil(t, err)
+		assert.Equal(t, expected, metrics, "%+v", query)
+
+		expectedHistory, err := library.GetWatcherMetricsHistory(ctx, query, 0)
+		require.Nil(t, err)
+		require.NotEmpty(t, expectedHistory)
+		history, err := service.GetWatcherMetricsHistory(ctx, query, 0)
+		require.Nil(t, err)
+		assert.Equal(t, expectedHistory, history, "%+v", query)
+
+		// Nothing was fetched since the latest metrics
+		history, err = service.GetWatcherMetricsHistory(ctx, query, expected.Timestamp+1)
+		require.Nil(t, err)
+		assert.Empty(t, history)
+	}
+
+	latest, err := service.GetLatestWatcherMetricsContext(ctx)
+	require.Nil(t, err)
+	assert.Equal(t, watcher.FifteenMinutes, latest.Window.Duration)
+
+	for _, client := range []Client{library, service} {
+		_, err = client.GetWatcherMetrics(ctx, watcher.MetricsQuery{Hosts: []string{"unknown"}})
+		assert.ErrorIs(t, err, ErrNoMetrics)
+		_, err = client.GetWatcherMetrics(ctx, watcher.MetricsQuery{Window: "1h"})
+		assert.NotNil(t, err)
+
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+		_, err = client.GetWatcherMetrics(canceled, watcher.MetricsQuery{})
+		assert.ErrorIs(t, err, context.Canceled)
+	}
+}
+
+// Serves the Watcher API of w, counting requests, or fails every request with status 500 if w is nil
+func TestClientsLatestMetricsWithoutFifteenMinutes(t *testing.T) {
+	library, service := newTestClients(t, time.Hour, 30*time.Minute, time.Minute)
+	ctx := context.Background()
+
+	expected, err := library.GetLatestWatcherMetricsContext(ctx)
+	require.Nil(t, err)
+	assert.Equal(t, "1h", expected.Window.Duration)
+	metrics, err := service.GetLatestWatcherMetricsContext(ctx)
+	require.Nil(t, err)
+	assert.Equal(t, expected, metrics)
+}
+
+func newTestReplica(t *testing.T, w *watcher.Watcher, requests *int32) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		if w == nil {
+			resp.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Handler().ServeHTTP(resp, r)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestServiceClientFailover(t *testing.T) {
+	var failedRequests, requests int32
+	failed := newTestReplica(t, nil, &failedRequests)
+	replica := newTestReplica(t, newTestWatcher(t), &requests)
+	client := newServiceClient(staticResolver{failed.URL, replica.URL}, -1)
+
+	for i := 0; i < 4; i++ {
+		metrics, err := client.GetLatestWatcherMetricsContext(context.Background())
+		require.Nil(t, err)
+		assert.NotEmpty(t, metrics.Data.NodeMetricsMap)
+	}
+	// Requests alternate between replicas until one fails, after which it is tried last
+	assert.Equal(t, int32(1), atomic.LoadInt32(&failedRequests))
+	assert.Equal(t, int32(4), atomic.LoadInt32(&requests))
+
+	// Queries the watcher can't serve are not failed over
+	_, err := client.GetWatcherMetrics(context.Background(), watcher.MetricsQuery{Hosts: []string{"unknown"}})
+	assert.ErrorIs(t, err, ErrNoMetrics)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&failedRequests))
+
+	client = newServiceClient(staticResolver{failed.URL}, -1)
+	client.retryPolicy.MaxAttempts = 1
+	_, err = client.GetLatestWatcherMetricsContext(context.Background())
+	assert.NotNil(t, err)
+}
+
+func TestServiceClientCache(t *testing.T) {
+	w := newTestWatcher(t)
+	var requests, conditionalRequests int32
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if r.Header.Get("If-None-Match") != "" {
+			atomic.AddInt32(&conditionalRequests, 1)
+		}
+		w.Handler().ServeHTTP(resp, r)
+	}))
+	defer server.Close()
+
+	// Responses are served from the cache for the TTL of the client, or as long as the watcher deems them fresh
+	var expected *watcher.WatcherMetrics
+	for _, ttl := range []time.Duration{time.Minute, 0} {
+		client := newServiceClient(staticResolver{server.URL}, ttl)
+		var err error
+		expected, err = client.GetLatestWatcherMetricsContext(context.Background())
+		require.Nil(t, err)
+		metrics, err := client.GetLatestWatcherMetricsContext(context.Background())
+		require.Nil(t, err)
+		assert.Equal(t, expected, metrics)
+	}
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+
+	// Every call requests the watcher if the TTL is negative, which answers Not Modified while the metrics are unchanged
+	client := newServiceClient(staticResolver{server.URL}, -1)
+	for i := 0; i < 2; i++ {
+		metrics, err := client.GetLatestWatcherMetricsContext(context.Background())
+		require.Nil(t, err)
+		assert.Equal(t, expected, metrics)
+	}
+	// Other queries are cached separately
+	_, err := client.GetWatcherMetrics(context.Background(), watcher.MetricsQuery{Window: watcher.FiveMinutes})
+	require.Nil(t, err)
+	assert.Equal(t, int32(5), atomic.LoadInt32(&requests))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&conditionalRequests))
+}
+
+func TestServiceClientCompression(t *testing.T) {
+	w := newTestWatcher(t)
+	expected, err := w.GetLatestWatcherMetrics(watcher.FifteenMinutes)
+	require.Nil(t, err)
+
+	for _, encoding := range []string{"", watcher.GzipEncoding, watcher.ZstdEncoding} {
+		server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, watcher.AcceptEncoding, r.Header.Get("Accept-Encoding"))
+			// Answers with a single encoding, as a watcher not supporting the others would
+			r.Header.Set("Accept-Encoding", encoding)
+			w.Handler().ServeHTTP(resp, r)
+			assert.Equal(t, encoding, resp.Header().Get("Content-Encoding"))
+		}))
+		client := newServiceClient(staticResolver{server.URL}, -1)
+		metrics, err := client.GetLatestWatcherMetricsContext(context.Background())
+		server.Close()
+		require.Nil(t, err, encoding)
+		assert.Equal(t, expected, metrics, encoding)
+	}
+}
+
+func TestServiceClientContentTypes(t *testing.T) {
+	w := newTestWatcher(t)
+	expected, err := w.GetLatestWatcherMetrics(watcher.FifteenMinutes)
+	require.Nil(t, err)
+
+	// Watchers serving JSON only ignore the Accept header
+	for accept, expectedContentType := range map[string]string{
+		watcher.AcceptProtobuf: watcher.ProtobufContentType,
+		"":                     watcher.JSONContentType,
+	} {
+		var contentType atomic.Value
+		server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, watcher.AcceptProtobuf, r.Header.Get("Accept"))
+			r.Header.Set("Accept", accept)
+			w.Handler().ServeHTTP(resp, r)
+			contentType.Store(resp.Header().Get("Content-Type"))
+		}))
+		client := newServiceClient(staticResolver{server.URL}, -1)
+		for i := 0; i < 2; i++ {
+			// The second response is Not Modified, decoded from the cache
+			metrics, err := client.GetLatestWatcherMetricsContext(context.Background())
+			require.Nil(t, err, accept)
+			assert.Equal(t, expected, metrics, accept)
+		}
+		server.Close()
+		assert.Equal(t, expectedContentType, contentType.Load(), accept)
+	}
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2021 PayPal
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/paypal/load-watcher/pkg/watcher"
+)
+
+const (
+	// Duration a watcher replica is tried last for after a request to it failed
+	endpointDownPeriod = 30 * time.Second
+)
+
+// Resolves to fixed watcher addresses
+type staticResolver []string
+
+func (r staticResolver) Endpoints() ([]string, error) {
+	return r, nil
+}
+
+// Spreads requests over watcher replicas round robin, trying replicas which failed recently last
+type endpointBalancer struct {
+	resolver watcher.EndpointResolver
+	next     uint32
+
+	mutex sync.Mutex
+	// Replicas which failed recently, with the time until which they are tried last
+	down map[string]time.Time
+}
+
+func newEndpointBalancer(resolver watcher.EndpointResolver) *endpointBalancer {
+	return &endpointBalancer{resolver: resolver, down: make(map[string]time.Time)}
+}
+
+// Returns the replicas to try in order for a request
+func (b *endpointBalancer) endpoints() ([]string, error) {
+	endpoints, err := b.resolver.Endpoints()
+	if err != nil {
+		return nil, err
+	}
+	if len(endpoints) == 0 {
+		return nil, errors.New("no watcher endpoints")
+	}
+
+	start := int(atomic.AddUint32(&b.next, 1)-1) % len(endpoints)
+	now := time.Now()
+	up := make([]string, 0, len(endpoints))
+	var down []string
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for endpoint, until := range b.down {
+		if !now.Before(until) {
+			delete(b.down, endpoint)
+		}
+	}
+	for i := range endpoints {
+		endpoint := endpoints[(start+i)%len(endpoints)]
+		if _, ok := b.down[endpoint]; ok {
+			down = append(down, endpoint)
+		} else {
+			up = append(up, endpoint)
+		}
+	}
+	return append(up, down...), nil
+}
+
+// Records the outcome of a request to a replica
+func (b *endpointBalancer) record(endpoint string, failed bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if failed {
+		b.down[endpoint] = time.Now().Add(endpointDownPeriod)
+	} else {
+		delete(b.down, endpoint)
+	}
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2020 PayPal
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sets the ETag, Last-Modified and Cache-Control headers of a response of metrics with a content type and encoding.
+// Returns true if the request matches the ETag with If-None-Match, in which case Not Modified is written out and the
+// response is done
+func (w *Watcher) writeValidators(resp http.ResponseWriter, r *http.Request, metrics *WatcherMetrics, contentType string, encoding string) bool {
+	etag := representationETag(metricsETag(metrics), contentType, encoding)
+	resp.Header().Set("ETag", etag)
+	resp.Header().Set("Last-Modified", time.Unix(metrics.Timestamp, 0).UTC().Format(http.TimeFormat))
+	resp.Header().Set("Cache-Control", "max-age="+strconv.Itoa(int(w.freshFor(metrics).Seconds())))
+	if !etagMatches(r.Header.Get("If-None-Match"), etag) {
+		return false
+	}
+	resp.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// Returns an ETag identifying a snapshot of metrics, from its timestamp, window and source. Stale snapshots
+// have another ETag than when they were fresh
+func metricsETag(metrics *WatcherMetrics) string {
+	hash := fnv.New64a()
+	fmt.Fprintf(hash, "%d/%s/%s/%t", metrics.Timestamp, metrics.Window.Duration, metrics.Source, metrics.Stale)
+	return fmt.Sprintf(`"%x"`, hash.Sum64())
+}
+
+// Returns true if an If-None-Match header matches etag, comparing weakly as required for If-None-Match
+func etagMatches(ifNoneMatch string, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns how long metrics stay the latest of their window, until the next fetch. Stale metrics are not fresh
+func (w *Watcher) freshFor(metrics *WatcherMetrics) time.Duration {
+	if metrics.Stale {
+		return 0
+	}
+	remaining := w.fetchInterval - time.Since(time.Unix(metrics.Timestamp, 0))
+	if remaining < 0 {
+		return 0
+	}
+	return remaining.Truncate(time.Second)
+}
@@ -0,0 +1,211 @@
+/*
+Copyright 2020 PayPal
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/francoispqt/gojay"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// Content types of responses of metrics
+	JSONContentType     = "application/json"
+	ProtobufContentType = "application/x-protobuf"
+	// Accept header of a client decoding protobuf responses, falling back to JSON
+	AcceptProtobuf = ProtobufContentType + ", " + JSONContentType + ";q=0.9"
+
+	// Content encodings of compressed responses
+	GzipEncoding = "gzip"
+	ZstdEncoding = "zstd"
+	// Accept-Encoding of a client decoding compressed responses, preferring zstd
+	AcceptEncoding = ZstdEncoding + ", " + GzipEncoding
+)
+
+// Compressing writer of a content encoding, reset to reuse it for another response
+type compressor interface {
+	io.WriteCloser
+	Reset(io.Writer)
+}
+
+var (
+	// Pools of compressors per content encoding
+	compressors = map[string]*sync.Pool{
+		GzipEncoding: {New: func() interface{} {
+			return gzip.NewWriter(nil)
+		}},
+		ZstdEncoding: {New: func() interface{} {
+			writer, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+			return writer
+		}},
+	}
+	nodeMetricsMapKey = []byte(`"NodeMetricsMap":{`)
+)
+
+// Returns the content type of a response of metrics negotiated from the Accept header of a request, protobuf if it
+// is accepted more than JSON. JSON is served otherwise, including to requests without an Accept header
+func negotiateContentType(accept string) string {
+	if accept == "" {
+		return JSONContentType
+	}
+	qualities := parseQualities(accept)
+	qualityOf := func(mediaType string) float64 {
+		if quality, ok := qualities[mediaType]; ok {
+			return quality
+		}
+		if quality, ok := qualities["application/*"]; ok {
+			return quality
+		}
+		return qualities["*/*"]
+	}
+	if qualityOf(ProtobufContentType) > qualityOf(JSONContentType) {
+		return ProtobufContentType
+	}
+	return JSONContentType
+}
+
+// Returns the content encoding of a response negotiated from the Accept-Encoding header of a request, zstd or gzip.
+// zstd is preferred when both are accepted as much. Returns the empty string for identity
+func negotiateEncoding(acceptEncoding string) string {
+	if acceptEncoding == "" {
+		return ""
+	}
+	qualities := parseQualities(acceptEncoding)
+	qualityOf := func(coding string) float64 {
+		if quality, ok := qualities[coding]; ok {
+			return quality
+		}
+		return qualities["*"]
+	}
+
+	encoding, best := "", 0.0
+	for _, coding := range []string{ZstdEncoding, GzipEncoding} {
+		if quality := qualityOf(coding); quality > best {
+			encoding, best = coding, quality
+		}
+	}
+	if identity, ok := qualities["identity"]; ok && identity > best {
+		return ""
+	}
+	return encoding
+}
+
+// Parses the values of an Accept or Accept-Encoding header into their quality, 1 unless set with the q parameter.
+// Values are lower cased, and those with an invalid quality are left out
+func parseQualities(header string) map[string]float64 {
+	qualities := make(map[string]float64)
+	for _, accepted := range strings.Split(header, ",") {
+		params := strings.Split(accepted, ";")
+		value := strings.ToLower(strings.TrimSpace(params[0]))
+		quality := 1.0
+		valid := true
+		for _, param := range params[1:] {
+			if q, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				var err error
+				quality, err = strconv.ParseFloat(q, 64)
+				valid = err == nil
+			}
+		}
+		if valid && value != "" {
+			qualities[value] = quality
+		}
+	}
+	return qualities
+}
+
+// Writes a response of status with a body written out by write, compressed with encoding unless it is empty.
+// The body is compressed while it is written, rather than once fully encoded
+func writeEncoded(resp http.ResponseWriter, encoding string, status int, write func(io.Writer) error) error {
+	if encoding == "" {
+		resp.WriteHeader(status)
+		return write(resp)
+	}
+
+	writers, ok := compressors[encoding]
+	if !ok {
+		return errors.New("unsupported content encoding " + encoding)
+	}
+	resp.Header().Set("Content-Encoding", encoding)
+	resp.WriteHeader(status)
+	writer := writers.Get().(compressor)
+	defer writers.Put(writer)
+	writer.Reset(resp)
+	if err := write(writer); err != nil {
+		return err
+	}
+	return writer.Close()
+}
+
+// Encodes metrics as JSON to writer one node at a time, so that the encoding of all nodes is never held at once.
+// The rest of metrics is encoded without nodes and split where they go
+func encodeWatcherMetrics(writer io.Writer, metrics *WatcherMetrics) error {
+	envelope := *metrics
+	envelope.Data.NodeMetricsMap = nil
+	encoded, err := gojay.MarshalJSONObject(&envelope)
+	if err != nil {
+		return err
+	}
+	split := bytes.Index(encoded, nodeMetricsMapKey)
+	if split < 0 {
+		return errors.New("unable to encode nodes of watcher metrics")
+	}
+	split += len(nodeMetricsMapKey)
+	if _, err = writer.Write(encoded[:split]); err != nil {
+		return err
+	}
+
+	first := true
+	for host, nodeMetrics := range metrics.Data.NodeMetricsMap {
+		// Encoded as {"host":{...}}, where the opening brace is replaced by the separator from the previous node
+		node, err := gojay.MarshalJSONObject(&NodeMetricsMap{host: nodeMetrics})
+		if err != nil {
+			return err
+		}
+		node = node[:len(node)-1]
+		if first {
+			node, first = node[1:], false
+		} else {
+			node[0] = ','
+		}
+		if _, err = writer.Write(node); err != nil {
+			return err
+		}
+	}
+
+	_, err = writer.Write(encoded[split:])
+	return err
+}
+
+// Returns the ETag of the representation with a content type and encoding of the metrics identified by etag
+func representationETag(etag string, contentType string, encoding string) string {
+	suffix := ""
+	if contentType == ProtobufContentType {
+		suffix += "-protobuf"
+	}
+	if encoding != "" {
+		suffix += "-" + encoding
+	}
+	return strings.TrimSuffix(etag, `"`) + suffix + `"`
+}
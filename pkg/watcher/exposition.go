@@ -0,0 +1,121 @@
+/*
+Copyright 2020 PayPal
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	// Serves cached node metrics in Prometheus text or OpenMetrics format
+	PrometheusUrl = "/watcher/prometheus"
+)
+
+var (
+	nodeMetricDesc = prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "", "node_metric"),
+		"Cached metric value of a node, as served to the scheduler.",
+		[]string{"host", "type", "operator", "window", "source", "name"}, nil)
+	nodeMetricsTimestampDesc = prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "", "node_metrics_timestamp_seconds"),
+		"Time the latest cached node metrics of a window were fetched.",
+		[]string{"window", "source"}, nil)
+)
+
+// Collects the latest cached metrics of every window selected by a query, without fetching from the metrics provider
+type nodeMetricsCollector struct {
+	w     *Watcher
+	query MetricsQuery
+}
+
+func (c nodeMetricsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- nodeMetricDesc
+	ch <- nodeMetricsTimestampDesc
+}
+
+func (c nodeMetricsCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, duration := range c.w.durations {
+		window := FormatWindowDuration(duration)
+		if c.query.Window != "" && c.query.Window != window {
+			continue
+		}
+		latest := c.w.latestWatcherMetrics(duration)
+		if latest == nil {
+			continue
+		}
+		metrics, err := c.query.Filter(latest)
+		if err != nil {
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(nodeMetricsTimestampDesc, prometheus.GaugeValue,
+			float64(metrics.Timestamp), window, metrics.Source)
+		for host, nodeMetrics := range metrics.Data.NodeMetricsMap {
+			// Label values need to be unique, so only the first of duplicate metrics is collected
+			seen := make(map[string]bool, len(nodeMetrics.Metrics))
+			for _, metric := range nodeMetrics.Metrics {
+				key := strings.Join([]string{metric.Type, metric.Operator, metric.Name}, "\xff")
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+				ch <- prometheus.MustNewConstMetric(nodeMetricDesc, prometheus.GaugeValue, metric.Value,
+					host, metric.Type, metric.Operator, window, metrics.Source, metric.Name)
+			}
+		}
+	}
+}
+
+// HTTP Handler for PrometheusUrl endpoint. It accepts the query parameters of the BaseUrl endpoint,
+// and serves the latest cached metrics of all windows unless a window is given
+func (w *Watcher) prometheusHandler(resp http.ResponseWriter, r *http.Request) {
+	query, err := ParseMetricsQuery(r.URL.Query())
+	if err == nil && query.Window != "" && !w.isWatched(query.Window) {
+		err = errNotWatched(query.Window)
+	}
+	if err != nil {
+		resp.WriteHeader(http.StatusBadRequest)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+	if query.Window != "" {
+		// Match the formatting of collected window labels, e.g. 60m is collected as 1h
+		duration, _ := ParseWindowDuration(query.Window)
+		query.Window = FormatWindowDuration(duration)
+	}
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(nodeMetricsCollector{w: w, query: query})
+	promhttp.HandlerFor(registry, promhttp.HandlerOpts{EnableOpenMetrics: true}).ServeHTTP(resp, r)
+}
+
+// Returns a copy of the latest cached metrics of a window, nil if none are cached or the Watcher is not started
+func (w *Watcher) latestWatcherMetrics(duration time.Duration) *WatcherMetrics {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	if !w.isStarted {
+		return nil
+	}
+	cache := w.windows[duration]
+	if len(cache.metrics) == 0 {
+		return nil
+	}
+	return w.deepCopyWatcherMetrics(&cache.metrics[len(cache.metrics)-1])
+}
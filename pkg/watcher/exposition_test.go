@@ -0,0 +1,84 @@
+/*
+Copyright 2020 PayPal
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNodeMetricsCollector(t *testing.T) {
+	client := &countingClient{MetricsProviderClient: NewTestMetricsServerClient()}
+	exposedWatcher := NewWatcher(client, WatcherOpts{Windows: []time.Duration{10 * time.Minute, 5 * time.Minute}})
+	exposedWatcher.isStarted = true
+	exposedWatcher.fetchOnce(context.Background(), 5*time.Minute)
+	fetches := atomic.LoadInt32(&client.fetches)
+
+	expected := `
+# HELP load_watcher_node_metric Cached metric value of a node, as served to the scheduler.
+# TYPE load_watcher_node_metric gauge
+load_watcher_node_metric{host="worker-1",name="test-cpu",operator="",source="TestServerClient",type="CPU",window="5m"} 21
+load_watcher_node_metric{host="worker-2",name="test-cpu",operator="",source="TestServerClient",type="CPU",window="5m"} 50
+`
+	collector := nodeMetricsCollector{w: exposedWatcher}
+	require.Nil(t, testutil.CollectAndCompare(collector, strings.NewReader(expected), "load_watcher_node_metric"))
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(nodeMetricsCollector{w: exposedWatcher, query: MetricsQuery{Hosts: []string{FirstNode}}})
+	count, err := testutil.GatherAndCount(registry, "load_watcher_node_metric")
+	require.Nil(t, err)
+	assert.Equal(t, 1, count)
+
+	// Collecting only reads the cache
+	assert.Equal(t, fetches, atomic.LoadInt32(&client.fetches))
+
+	// Cached metrics are not collected from a stopped Watcher
+	exposedWatcher.isStarted = false
+	count, err = testutil.GatherAndCount(registry, "load_watcher_node_metric")
+	require.Nil(t, err)
+	assert.Equal(t, 0, count)
+}
+
+func TestWatcherPrometheusAPI(t *testing.T) {
+	req, err := http.NewRequest("GET", PrometheusUrl+"?window=10m&host="+SecondNode, nil)
+	require.Nil(t, err)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(w.prometheusHandler).ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	body := rr.Body.String()
+	assert.Contains(t, body, `load_watcher_node_metric{host="worker-2",name="test-cpu",operator="",source="TestServerClient",type="CPU",window="10m"} 65`)
+	assert.Contains(t, body, `load_watcher_node_metrics_timestamp_seconds{source="TestServerClient",window="10m"}`)
+	assert.NotContains(t, body, FirstNode)
+	assert.NotContains(t, body, `window="15m"`)
+
+	req, err = http.NewRequest("GET", PrometheusUrl+"?window=1h", nil)
+	require.Nil(t, err)
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(w.prometheusHandler).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
@@ -0,0 +1,155 @@
+/*
+Copyright 2020 PayPal
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	LivenessUrl  = "/watcher/livez"
+	ReadinessUrl = "/watcher/readyz"
+	// Query parameter to get a detailed JSON health report from the liveness and readiness endpoints
+	VerboseParam = "verbose"
+
+	healthStatusOK          = "ok"
+	healthStatusUnavailable = "unavailable"
+)
+
+// HealthReport Details the health of the Watcher, served by the liveness and readiness endpoints in verbose mode
+type HealthReport struct {
+	Status   string               `json:"status"`
+	Started  bool                 `json:"started"`
+	Provider *ProviderHealth      `json:"provider,omitempty"`
+	Windows  []WindowHealthReport `json:"windows"`
+}
+
+// ProviderHealth Reports reachability of the metrics provider
+type ProviderHealth struct {
+	Name      string `json:"name"`
+	Reachable bool   `json:"reachable"`
+	Error     string `json:"error,omitempty"`
+}
+
+// WindowHealthReport Reports the fetch status of a window
+type WindowHealthReport struct {
+	Window              string `json:"window"`
+	LastSuccess         int64  `json:"lastSuccess,omitempty"` // Unix seconds
+	LastFailure         int64  `json:"lastFailure,omitempty"` // Unix seconds
+	LastError           string `json:"lastError,omitempty"`
+	ConsecutiveFailures int    `json:"consecutiveFailures"`
+	CacheSize           int    `json:"cacheSize"`
+	LatestTimestamp     int64  `json:"latestTimestamp,omitempty"`
+	Stale               bool   `json:"stale"`
+}
+
+// IsReady Returns true if the Watcher is started and has fresh metrics cached for at least one window
+func (w *Watcher) IsReady() bool {
+	w.mutex.RLock()
+	started := w.isStarted
+	w.mutex.RUnlock()
+	if !started {
+		return false
+	}
+	for _, status := range w.WindowStatuses() {
+		if status.CacheSize > 0 && !status.Stale {
+			return true
+		}
+	}
+	return false
+}
+
+// HTTP Handler for LivenessUrl endpoint. The Watcher is live as long as it serves requests
+func (w *Watcher) livenessHandler(resp http.ResponseWriter, r *http.Request) {
+	w.writeHealth(resp, r, true)
+}
+
+// HTTP Handler for ReadinessUrl endpoint. The Watcher is ready once it has fresh metrics cached
+func (w *Watcher) readinessHandler(resp http.ResponseWriter, r *http.Request) {
+	w.writeHealth(resp, r, w.IsReady())
+}
+
+func (w *Watcher) writeHealth(resp http.ResponseWriter, r *http.Request, healthy bool) {
+	status, code := healthStatusOK, http.StatusOK
+	if !healthy {
+		status, code = healthStatusUnavailable, http.StatusServiceUnavailable
+	}
+	if _, verbose := r.URL.Query()[VerboseParam]; !verbose {
+		resp.WriteHeader(code)
+		resp.Write([]byte(status))
+		return
+	}
+
+	report := w.healthReport(r.Context())
+	report.Status = status
+	bytes, err := json.Marshal(report)
+	if err != nil {
+		log.Error(err)
+		resp.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	resp.Header().Set("Content-Type", "application/json")
+	resp.WriteHeader(code)
+	if _, err = resp.Write(bytes); err != nil {
+		log.Error(err)
+	}
+}
+
+// Builds a health report, checking reachability of the metrics provider
+func (w *Watcher) healthReport(ctx context.Context) HealthReport {
+	w.mutex.RLock()
+	report := HealthReport{Started: w.isStarted}
+	w.mutex.RUnlock()
+
+	provider := &ProviderHealth{Name: w.client.Name(), Reachable: true}
+	if status, err := w.client.HealthContext(ctx); status != 0 {
+		provider.Reachable = false
+		if err != nil {
+			provider.Error = err.Error()
+		}
+	}
+	report.Provider = provider
+
+	for _, status := range w.WindowStatuses() {
+		window := WindowHealthReport{
+			Window:              status.Window,
+			LastSuccess:         unixOrZero(status.LastSuccess),
+			LastFailure:         unixOrZero(status.LastFailure),
+			ConsecutiveFailures: status.ConsecutiveFailures,
+			CacheSize:           status.CacheSize,
+			LatestTimestamp:     status.LatestTimestamp,
+			Stale:               status.Stale,
+		}
+		if status.LastError != nil {
+			window.LastError = status.LastError.Error()
+		}
+		report.Windows = append(report.Windows, window)
+	}
+	return report
+}
+
+func unixOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
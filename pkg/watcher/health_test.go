@@ -0,0 +1,86 @@
+/*
+Copyright 2020 PayPal
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func serveHealth(t *testing.T, handler http.HandlerFunc, url string) *httptest.ResponseRecorder {
+	req, err := http.NewRequest("GET", url, nil)
+	require.Nil(t, err)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestWatcherLivenessReadiness(t *testing.T) {
+	assert.Equal(t, http.StatusOK, serveHealth(t, w.livenessHandler, LivenessUrl).Code)
+	assert.Equal(t, http.StatusOK, serveHealth(t, w.readinessHandler, ReadinessUrl).Code)
+	assert.True(t, w.IsReady())
+
+	unstartedWatcher := NewWatcher(NewTestMetricsServerClient(), WatcherOpts{})
+	assert.Equal(t, http.StatusOK, serveHealth(t, unstartedWatcher.livenessHandler, LivenessUrl).Code)
+	assert.Equal(t, http.StatusServiceUnavailable, serveHealth(t, unstartedWatcher.readinessHandler, ReadinessUrl).Code)
+
+	// Stale metrics do not make the Watcher ready
+	staleWatcher := NewWatcher(NewTestMetricsServerClient(), WatcherOpts{MaxAge: time.Minute})
+	staleWatcher.isStarted = true
+	metrics := metricMapToWatcherMetrics(FiveMinutesMetricsMap, TestServerClientName, *CurrentFiveMinuteWindow())
+	metrics.Timestamp = time.Now().Add(-time.Hour).Unix()
+	staleWatcher.appendWatcherMetrics(5*time.Minute, &metrics)
+	assert.False(t, staleWatcher.IsReady())
+	assert.Equal(t, http.StatusServiceUnavailable, serveHealth(t, staleWatcher.readinessHandler, ReadinessUrl).Code)
+}
+
+func TestWatcherReadinessVerbose(t *testing.T) {
+	client := failingClient{MetricsProviderClient: NewTestMetricsServerClient()}
+	verboseWatcher := NewWatcher(client, WatcherOpts{Windows: []time.Duration{10 * time.Minute, 5 * time.Minute}})
+	verboseWatcher.isStarted = true
+	metrics := metricMapToWatcherMetrics(FiveMinutesMetricsMap, client.Name(), *CurrentFiveMinuteWindow())
+	verboseWatcher.appendWatcherMetrics(5*time.Minute, &metrics)
+	verboseWatcher.fetchOnce(context.Background(), 10*time.Minute)
+
+	rr := serveHealth(t, verboseWatcher.readinessHandler, ReadinessUrl+"?"+VerboseParam)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+
+	var report HealthReport
+	require.Nil(t, json.Unmarshal(rr.Body.Bytes(), &report))
+	assert.Equal(t, healthStatusOK, report.Status)
+	assert.True(t, report.Started)
+	require.NotNil(t, report.Provider)
+	assert.Equal(t, TestServerClientName, report.Provider.Name)
+	assert.True(t, report.Provider.Reachable)
+	require.Len(t, report.Windows, 2)
+	assert.Equal(t, TenMinutes, report.Windows[0].Window)
+	assert.Equal(t, 1, report.Windows[0].ConsecutiveFailures)
+	assert.NotEmpty(t, report.Windows[0].LastError)
+	assert.Equal(t, 0, report.Windows[0].CacheSize)
+	assert.Equal(t, FiveMinutes, report.Windows[1].Window)
+	assert.Equal(t, 1, report.Windows[1].CacheSize)
+	assert.NotZero(t, report.Windows[1].LastSuccess)
+	assert.False(t, report.Windows[1].Stale)
+}
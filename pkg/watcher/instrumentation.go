@@ -0,0 +1,210 @@
+/*
+Copyright 2020 PayPal
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	MetricsUrl = "/metrics"
+
+	metricsNamespace = "load_watcher"
+)
+
+// Prometheus metrics about the Watcher itself, registered on a registry per Watcher
+type instruments struct {
+	registry         *prometheus.Registry
+	fetchDuration    *prometheus.HistogramVec
+	fetchErrors      *prometheus.CounterVec
+	fetchHosts       *prometheus.GaugeVec
+	providerDuration *prometheus.HistogramVec
+	providerErrors   *prometheus.CounterVec
+	httpRequests     *prometheus.CounterVec
+	httpDuration     *prometheus.HistogramVec
+}
+
+func newInstruments(w *Watcher) *instruments {
+	i := &instruments{
+		registry: prometheus.NewRegistry(),
+		fetchDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "fetch_duration_seconds",
+			Help:      "Duration of fetching metrics of all hosts for a window.",
+			Buckets:   []float64{.1, .25, .5, 1, 2.5, 5, 10, 20, 30, 60},
+		}, []string{"provider", "window"}),
+		fetchErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "fetch_errors_total",
+			Help:      "Number of failed fetches of metrics for a window.",
+		}, []string{"provider", "window"}),
+		fetchHosts: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "fetch_hosts",
+			Help:      "Number of hosts returned by the last successful fetch for a window.",
+		}, []string{"provider", "window"}),
+		providerDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "provider_request_duration_seconds",
+			Help:      "Duration of metrics provider client calls.",
+			Buckets:   []float64{.01, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+		}, []string{"provider", "method"}),
+		providerErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "provider_request_errors_total",
+			Help:      "Number of failed metrics provider client calls.",
+		}, []string{"provider", "method"}),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_requests_total",
+			Help:      "Number of HTTP requests served by handler, method and status code.",
+		}, []string{"handler", "method", "code"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Duration of HTTP requests served by handler.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"handler"}),
+	}
+	i.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		i.fetchDuration,
+		i.fetchErrors,
+		i.fetchHosts,
+		i.providerDuration,
+		i.providerErrors,
+		i.httpRequests,
+		i.httpDuration,
+		cacheCollector{w},
+	)
+	return i
+}
+
+// Returns the handler serving the Watcher metrics
+func (i *instruments) handler() http.Handler {
+	return promhttp.HandlerFor(i.registry, promhttp.HandlerOpts{Registry: i.registry, EnableOpenMetrics: true})
+}
+
+// Wraps handler to count requests and observe their latency
+func (i *instruments) instrumentHandler(name string, handler http.HandlerFunc) http.Handler {
+	labels := prometheus.Labels{"handler": name}
+	return promhttp.InstrumentHandlerDuration(i.httpDuration.MustCurryWith(labels),
+		promhttp.InstrumentHandlerCounter(i.httpRequests.MustCurryWith(labels), handler))
+}
+
+func (i *instruments) observeFetch(provider string, window string, start time.Time, hosts int, err error) {
+	i.fetchDuration.WithLabelValues(provider, window).Observe(time.Since(start).Seconds())
+	if err != nil {
+		i.fetchErrors.WithLabelValues(provider, window).Inc()
+		return
+	}
+	i.fetchHosts.WithLabelValues(provider, window).Set(float64(hosts))
+}
+
+func (i *instruments) observeProviderCall(provider string, method string, start time.Time, err error) {
+	i.providerDuration.WithLabelValues(provider, method).Observe(time.Since(start).Seconds())
+	if err != nil {
+		i.providerErrors.WithLabelValues(provider, method).Inc()
+	}
+}
+
+// Collects the age and size of the Watcher cache per window when scraped
+type cacheCollector struct {
+	w *Watcher
+}
+
+var (
+	cacheAgeDesc = prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "", "cache_age_seconds"),
+		"Age of the latest cached metrics for a window.", []string{"window"}, nil)
+	cacheSizeDesc = prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "", "cache_size"),
+		"Number of cached metrics for a window.", []string{"window"}, nil)
+	consecutiveFailuresDesc = prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "", "fetch_consecutive_failures"),
+		"Number of failed fetches for a window since the last successful one.", []string{"window"}, nil)
+)
+
+func (c cacheCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- cacheAgeDesc
+	ch <- cacheSizeDesc
+	ch <- consecutiveFailuresDesc
+}
+
+func (c cacheCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, status := range c.w.WindowStatuses() {
+		ch <- prometheus.MustNewConstMetric(cacheSizeDesc, prometheus.GaugeValue, float64(status.CacheSize), status.Window)
+		ch <- prometheus.MustNewConstMetric(consecutiveFailuresDesc, prometheus.GaugeValue, float64(status.ConsecutiveFailures), status.Window)
+		if status.CacheSize > 0 {
+			age := time.Since(time.Unix(status.LatestTimestamp, 0)).Seconds()
+			ch <- prometheus.MustNewConstMetric(cacheAgeDesc, prometheus.GaugeValue, age, status.Window)
+		}
+	}
+}
+
+var (
+	_ ContextMetricsProviderClient    = instrumentedClient{}
+	_ ContextPodMetricsProviderClient = instrumentedClient{}
+)
+
+// Observes the latency and errors of every call to a metrics provider client
+type instrumentedClient struct {
+	client      ContextMetricsProviderAdapter
+	instruments *instruments
+}
+
+func (c instrumentedClient) Name() string {
+	return c.client.Name()
+}
+
+func (c instrumentedClient) FetchHostMetricsContext(ctx context.Context, host string, window *Window) ([]Metric, error) {
+	start := time.Now()
+	metrics, err := c.client.FetchHostMetricsContext(ctx, host, window)
+	c.instruments.observeProviderCall(c.client.Name(), "FetchHostMetrics", start, err)
+	return metrics, err
+}
+
+func (c instrumentedClient) FetchAllHostsMetricsContext(ctx context.Context, window *Window) (map[string][]Metric, error) {
+	start := time.Now()
+	metrics, err := c.client.FetchAllHostsMetricsContext(ctx, window)
+	c.instruments.observeProviderCall(c.client.Name(), "FetchAllHostsMetrics", start, err)
+	return metrics, err
+}
+
+// Only called if the client supports pod metrics
+func (c instrumentedClient) FetchAllPodsMetricsContext(ctx context.Context, window *Window) (PodMetricsMap, error) {
+	start := time.Now()
+	metrics, err := c.client.FetchAllPodsMetricsContext(ctx, window)
+	c.instruments.observeProviderCall(c.client.Name(), "FetchAllPodsMetrics", start, err)
+	return metrics, err
+}
+
+func (c instrumentedClient) HealthContext(ctx context.Context) (int, error) {
+	start := time.Now()
+	status, err := c.client.HealthContext(ctx)
+	observedErr := err
+	if observedErr == nil && status != 0 {
+		observedErr = fmt.Errorf("unhealthy status %v", status)
+	}
+	c.instruments.observeProviderCall(c.client.Name(), "Health", start, observedErr)
+	return status, err
+}
@@ -0,0 +1,58 @@
+/*
+Copyright 2020 PayPal
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatcherSelfMetrics(t *testing.T) {
+	client := failingClient{MetricsProviderClient: NewTestMetricsServerClient()}
+	instrumentedWatcher := NewWatcher(client, WatcherOpts{Windows: []time.Duration{5 * time.Minute}})
+	instrumentedWatcher.isStarted = true
+	instrumentedWatcher.fetchOnce(context.Background(), 5*time.Minute)
+	metrics := metricMapToWatcherMetrics(FiveMinutesMetricsMap, client.Name(), *CurrentFiveMinuteWindow())
+	instrumentedWatcher.appendWatcherMetrics(5*time.Minute, &metrics)
+
+	server := httptest.NewServer(instrumentedWatcher.Handler())
+	defer server.Close()
+	resp, err := http.Get(server.URL + BaseUrl)
+	require.Nil(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(instrumentedWatcher.instruments.fetchErrors.WithLabelValues(TestServerClientName, FiveMinutes)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(instrumentedWatcher.instruments.providerErrors.WithLabelValues(TestServerClientName, "FetchAllHostsMetrics")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(instrumentedWatcher.instruments.httpRequests.WithLabelValues(BaseUrl, "get", "200")))
+
+	resp, err = http.Get(server.URL + MetricsUrl)
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	count, err := testutil.GatherAndCount(instrumentedWatcher.instruments.registry,
+		"load_watcher_fetch_duration_seconds", "load_watcher_cache_age_seconds", "load_watcher_cache_size",
+		"load_watcher_http_request_duration_seconds")
+	require.Nil(t, err)
+	assert.Equal(t, 4, count)
+}
@@ -0,0 +1,223 @@
+/*
+Copyright 2020 PayPal
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metricsprovider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/paypal/load-watcher/pkg/watcher"
+)
+
+var _ watcher.PodMetricsProviderClient = compositeClient{}
+var _ watcher.ContextPodMetricsProviderClient = compositeClient{}
+var _ watcher.ContextMetricsProviderClient = compositeClient{}
+
+// Merges the metrics of several metrics provider clients, e.g. CPU and memory from metrics-server and energy from Prometheus.
+// Metrics of different clients with the same name, type and operator are de-duplicated in order of precedence
+type compositeClient struct {
+	// Clients in order of precedence, highest first
+	clients []watcher.ContextMetricsProviderAdapter
+}
+
+// NewCompositeClient Returns a client merging the metrics of clients, given in order of precedence, highest first
+func NewCompositeClient(clients ...watcher.MetricsProviderClient) (watcher.MetricsProviderClient, error) {
+	if len(clients) == 0 {
+		return nil, errors.New("composite client needs at least one metrics provider client")
+	}
+	adapters := make([]watcher.ContextMetricsProviderAdapter, 0, len(clients))
+	for _, client := range clients {
+		adapters = append(adapters, watcher.WithContext(client))
+	}
+	return compositeClient{clients: adapters}, nil
+}
+
+// Close Closes the clients which are io.Closers
+func (c compositeClient) Close() error {
+	var errs []error
+	for _, client := range c.clients {
+		if err := client.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Name Returns the names of the clients joined with +, e.g. KubernetesMetricsServer+Prometheus
+func (c compositeClient) Name() string {
+	names := make([]string, 0, len(c.clients))
+	for _, client := range c.clients {
+		names = append(names, client.Name())
+	}
+	return strings.Join(names, "+")
+}
+
+func (c compositeClient) FetchHostMetrics(host string, window *watcher.Window) ([]watcher.Metric, error) {
+	return c.FetchHostMetricsContext(context.Background(), host, window)
+}
+
+func (c compositeClient) FetchHostMetricsContext(ctx context.Context, host string, window *watcher.Window) ([]watcher.Metric, error) {
+	results := make([][]watcher.Metric, len(c.clients))
+	err := c.fetchAll(func(i int, client watcher.ContextMetricsProviderAdapter) (bool, error) {
+		metrics, err := client.FetchHostMetricsContext(ctx, host, window)
+		results[i] = metrics
+		return len(metrics) > 0, err
+	})
+	return mergeMetrics(results...), err
+}
+
+func (c compositeClient) FetchAllHostsMetrics(window *watcher.Window) (map[string][]watcher.Metric, error) {
+	return c.FetchAllHostsMetricsContext(context.Background(), window)
+}
+
+func (c compositeClient) FetchAllHostsMetricsContext(ctx context.Context, window *watcher.Window) (map[string][]watcher.Metric, error) {
+	results := make([]map[string][]watcher.Metric, len(c.clients))
+	err := c.fetchAll(func(i int, client watcher.ContextMetricsProviderAdapter) (bool, error) {
+		metrics, err := client.FetchAllHostsMetricsContext(ctx, window)
+		results[i] = metrics
+		return len(metrics) > 0, err
+	})
+
+	hostMetrics := make(map[string][]watcher.Metric)
+	for _, clientMetrics := range results {
+		for host, metrics := range clientMetrics {
+			hostMetrics[host] = mergeMetrics(hostMetrics[host], metrics)
+		}
+	}
+	return hostMetrics, err
+}
+
+// FetchAllPodsMetrics Merges the pod metrics of the clients supporting them. Returns nil if none of them is configured for pod metrics
+func (c compositeClient) FetchAllPodsMetrics(window *watcher.Window) (watcher.PodMetricsMap, error) {
+	return c.FetchAllPodsMetricsContext(context.Background(), window)
+}
+
+func (c compositeClient) FetchAllPodsMetricsContext(ctx context.Context, window *watcher.Window) (watcher.PodMetricsMap, error) {
+	results := make([]watcher.PodMetricsMap, len(c.clients))
+	err := c.fetchAll(func(i int, client watcher.ContextMetricsProviderAdapter) (bool, error) {
+		if !client.SupportsPodMetrics() {
+			return false, nil
+		}
+		pods, err := client.FetchAllPodsMetricsContext(ctx, window)
+		results[i] = pods
+		return len(pods) > 0, err
+	})
+
+	var podMetrics watcher.PodMetricsMap
+	for _, clientPods := range results {
+		for key, pod := range clientPods {
+			if podMetrics == nil {
+				podMetrics = make(watcher.PodMetricsMap)
+			}
+			merged, ok := podMetrics[key]
+			if !ok {
+				podMetrics[key] = pod
+				continue
+			}
+			if merged.Node == "" {
+				merged.Node = pod.Node
+			}
+			merged.Metrics = mergeMetrics(merged.Metrics, pod.Metrics)
+			podMetrics[key] = merged
+		}
+	}
+	return podMetrics, err
+}
+
+// Health Returns -1 along with the errors of the unhealthy clients if any of them is unhealthy
+func (c compositeClient) Health() (int, error) {
+	return c.HealthContext(context.Background())
+}
+
+func (c compositeClient) HealthContext(ctx context.Context) (int, error) {
+	errs := make([]error, len(c.clients))
+	c.forEach(func(i int, client watcher.ContextMetricsProviderAdapter) {
+		status, err := client.HealthContext(ctx)
+		if status == 0 {
+			return
+		}
+		if err == nil {
+			err = errors.New("unhealthy")
+		}
+		errs[i] = fmt.Errorf("%v: %w", client.Name(), err)
+	})
+	if err := errors.Join(errs...); err != nil {
+		return -1, err
+	}
+	return 0, nil
+}
+
+// Calls fetch for every client concurrently. fetch returns true if the client returned metrics. Returns nil if all
+// clients succeeded, a PartialMetricsError if some clients failed while others returned metrics, and the errors
+// of the failed clients otherwise
+func (c compositeClient) fetchAll(fetch func(i int, client watcher.ContextMetricsProviderAdapter) (bool, error)) error {
+	errs := make([]error, len(c.clients))
+	fetched := make([]bool, len(c.clients))
+	c.forEach(func(i int, client watcher.ContextMetricsProviderAdapter) {
+		var err error
+		if fetched[i], err = fetch(i, client); err != nil {
+			errs[i] = fmt.Errorf("%v: %w", client.Name(), err)
+		}
+	})
+	err := errors.Join(errs...)
+	if err == nil {
+		return nil
+	}
+	for _, ok := range fetched {
+		if ok {
+			return &watcher.PartialMetricsError{Err: err}
+		}
+	}
+	return err
+}
+
+// Calls f for every client concurrently, and waits for all calls to return
+func (c compositeClient) forEach(f func(i int, client watcher.ContextMetricsProviderAdapter)) {
+	var wg sync.WaitGroup
+	for i, client := range c.clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f(i, client)
+		}()
+	}
+	wg.Wait()
+}
+
+// Merges lists of metrics given in order of precedence, dropping metrics with the name, type and operator
+// of a metric of a previous list
+func mergeMetrics(lists ...[]watcher.Metric) []watcher.Metric {
+	type key struct {
+		name, metricType, operator string
+	}
+	var merged []watcher.Metric
+	seen := make(map[key]bool)
+	for _, metrics := range lists {
+		for _, metric := range metrics {
+			k := key{metric.Name, metric.Type, metric.Operator}
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+			merged = append(merged, metric)
+		}
+	}
+	return merged
+}
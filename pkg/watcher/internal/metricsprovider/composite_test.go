@@ -0,0 +1,136 @@
+package metricsprovider
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/paypal/load-watcher/pkg/watcher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Returns fixed metrics, or fails
+type staticClient struct {
+	name      string
+	metrics   map[string][]watcher.Metric
+	pods      watcher.PodMetricsMap
+	err       error
+	unhealthy bool
+}
+
+func (c staticClient) Name() string {
+	return c.name
+}
+
+func (c staticClient) FetchHostMetrics(host string, window *watcher.Window) ([]watcher.Metric, error) {
+	return c.metrics[host], c.err
+}
+
+func (c staticClient) FetchAllHostsMetrics(window *watcher.Window) (map[string][]watcher.Metric, error) {
+	return c.metrics, c.err
+}
+
+func (c staticClient) Health() (int, error) {
+	if c.err != nil {
+		return -1, c.err
+	}
+	if c.unhealthy {
+		return -1, nil
+	}
+	return 0, nil
+}
+
+// Also returns fixed pod metrics
+type staticPodsClient struct {
+	staticClient
+}
+
+func (c staticPodsClient) FetchAllPodsMetrics(window *watcher.Window) (watcher.PodMetricsMap, error) {
+	return c.pods, c.err
+}
+
+var (
+	k8sCPU     = watcher.Metric{Type: watcher.CPU, Operator: watcher.Average, Value: 20}
+	promCPU    = watcher.Metric{Type: watcher.CPU, Operator: watcher.Average, Value: 25}
+	promEnergy = watcher.Metric{Name: "kepler_node_platform_joules_total", Type: watcher.Energy, Operator: watcher.Average, Value: 300}
+)
+
+func TestCompositeClientMergesMetrics(t *testing.T) {
+	k8s := staticClient{name: watcher.K8sClientName, metrics: map[string][]watcher.Metric{
+		"node-1": {k8sCPU},
+	}}
+	prom := staticClient{name: watcher.PromClientName, metrics: map[string][]watcher.Metric{
+		"node-1": {promCPU, promEnergy},
+		"node-2": {promEnergy},
+	}}
+
+	client, err := NewCompositeClient(k8s, prom)
+	require.Nil(t, err)
+	assert.Equal(t, "KubernetesMetricsServer+Prometheus", client.Name())
+	metrics, err := client.FetchAllHostsMetrics(watcher.CurrentFifteenMinuteWindow())
+	require.Nil(t, err)
+	assert.Equal(t, map[string][]watcher.Metric{
+		"node-1": {k8sCPU, promEnergy},
+		"node-2": {promEnergy},
+	}, metrics)
+
+	// Precedence follows the order of clients
+	client, err = NewCompositeClient(prom, k8s)
+	require.Nil(t, err)
+	hostMetrics, err := client.FetchHostMetrics("node-1", watcher.CurrentFifteenMinuteWindow())
+	require.Nil(t, err)
+	assert.Equal(t, []watcher.Metric{promCPU, promEnergy}, hostMetrics)
+	status, err := client.Health()
+	assert.Equal(t, 0, status)
+	assert.Nil(t, err)
+
+	_, err = NewCompositeClient()
+	assert.NotNil(t, err)
+}
+
+func TestCompositeClientFailures(t *testing.T) {
+	k8s := staticClient{name: watcher.K8sClientName, metrics: map[string][]watcher.Metric{"node-1": {k8sCPU}}}
+	datadog := staticClient{name: watcher.DatadogClientName, err: errors.New("outage")}
+
+	client, err := NewCompositeClient(datadog, k8s)
+	require.Nil(t, err)
+	metrics, err := client.FetchAllHostsMetrics(watcher.CurrentFifteenMinuteWindow())
+	var partialErr *watcher.PartialMetricsError
+	require.ErrorAs(t, err, &partialErr)
+	assert.Contains(t, err.Error(), "Datadog: outage")
+	assert.Equal(t, map[string][]watcher.Metric{"node-1": {k8sCPU}}, metrics)
+	status, err := client.Health()
+	assert.Equal(t, -1, status)
+	assert.EqualError(t, err, "Datadog: outage")
+
+	client, err = NewCompositeClient(datadog, staticClient{name: watcher.PromClientName, err: errors.New("timeout")})
+	require.Nil(t, err)
+	metrics, err = client.FetchAllHostsMetrics(watcher.CurrentFifteenMinuteWindow())
+	assert.Empty(t, metrics)
+	require.NotNil(t, err)
+	assert.False(t, errors.As(err, &partialErr))
+	assert.Contains(t, err.Error(), "Prometheus: timeout")
+}
+
+func TestCompositeClientPodMetrics(t *testing.T) {
+	k8s := staticPodsClient{staticClient{name: watcher.K8sClientName, pods: watcher.PodMetricsMap{
+		"default/web-1": {Namespace: "default", Name: "web-1", Node: "node-1", Metrics: []watcher.Metric{k8sCPU}},
+	}}}
+	prom := staticPodsClient{staticClient{name: watcher.PromClientName, pods: watcher.PodMetricsMap{
+		"default/web-1": {Namespace: "default", Name: "web-1", Metrics: []watcher.Metric{promCPU, promEnergy}},
+	}}}
+
+	client, err := NewCompositeClient(staticClient{name: watcher.DatadogClientName}, k8s, prom)
+	require.Nil(t, err)
+	pods, err := client.(watcher.PodMetricsProviderClient).FetchAllPodsMetrics(watcher.CurrentFifteenMinuteWindow())
+	require.Nil(t, err)
+	assert.Equal(t, watcher.PodMetricsMap{
+		"default/web-1": {Namespace: "default", Name: "web-1", Node: "node-1", Metrics: []watcher.Metric{k8sCPU, promEnergy}},
+	}, pods)
+
+	client, err = NewCompositeClient(staticClient{name: watcher.DatadogClientName})
+	require.Nil(t, err)
+	pods, err = client.(watcher.PodMetricsProviderClient).FetchAllPodsMetrics(watcher.CurrentFifteenMinuteWindow())
+	require.Nil(t, err)
+	assert.Nil(t, pods)
+}
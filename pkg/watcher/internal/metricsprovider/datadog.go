@@ -98,8 +98,12 @@ func (s datadogClient) Name() string {
 // This function fetches metrics for a host during the watcher.window.
 // It returns an array of watcher.Metric
 func (s datadogClient) FetchHostMetrics(host string, window *watcher.Window) ([]watcher.Metric, error) {
+	return s.FetchHostMetricsContext(context.Background(), host, window)
+}
+
+func (s datadogClient) FetchHostMetricsContext(ctx context.Context, host string, window *watcher.Window) ([]watcher.Metric, error) {
 	log.Debugf("fetching metrics for host %v", host)
-	metricsMap, err := s.getMetricsHelper(window, host)
+	metricsMap, err := s.getMetricsHelper(ctx, window, host)
 	if err == nil {
 		// Get the value from the map with only 1 key, hostname
 		for _, metrics := range metricsMap {
@@ -112,17 +116,25 @@ func (s datadogClient) FetchHostMetrics(host string, window *watcher.Window) ([]
 // This function fetches all hosts metrics during the watcher.window.
 // It returns a map of hostname and an array of watcher.Metric
 func (s datadogClient) FetchAllHostsMetrics(window *watcher.Window) (map[string][]watcher.Metric, error) {
-	return s.getMetricsHelper(window, "*")
+	return s.FetchAllHostsMetricsContext(context.Background(), window)
+}
+
+func (s datadogClient) FetchAllHostsMetricsContext(ctx context.Context, window *watcher.Window) (map[string][]watcher.Metric, error) {
+	return s.getMetricsHelper(ctx, window, "*")
 }
 
 func (s datadogClient) Health() (int, error) {
-	return ping(s.client, "https://"+s.datadogAddress)
+	return s.HealthContext(context.Background())
+}
+
+func (s datadogClient) HealthContext(ctx context.Context) (int, error) {
+	return ping(ctx, s.client, "https://"+s.datadogAddress)
 }
 
 // Simple ping utility to a given URL
 // Returns -1 if unhealthy, 0 if healthy along with error if any
-func ping(client http.Client, url string) (int, error) {
-	req, err := http.NewRequest("GET", url, nil)
+func ping(ctx context.Context, client http.Client, url string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return -1, err
 	}
@@ -156,9 +168,9 @@ func addDatadogMetadata(metric *watcher.Metric, isCPU bool) {
 
 // This method constructs datadog query for CPU and memory metrics for all/a host(s)
 // It returns a map of hostname and array of watcher.Metric
-func (s datadogClient) getMetricsHelper(window *watcher.Window, host string) (map[string][]watcher.Metric, error) {
-	ctx := context.WithValue(
-		context.Background(),
+func (s datadogClient) getMetricsHelper(ctx context.Context, window *watcher.Window, host string) (map[string][]watcher.Metric, error) {
+	ctx = context.WithValue(
+		ctx,
 		datadog.ContextAPIKeys,
 		map[string]datadog.APIKey{
 			"apiKeyAuth": {
@@ -203,20 +215,23 @@ func (s datadogClient) getMetricsHelper(window *watcher.Window, host string) (ma
 	}
 	configuration := datadog.NewConfiguration()
 	configuration.SetUnstableOperationEnabled("v2.QueryTimeseriesData", true)
+	// Requests share the transport and timeout of the client
+	configuration.HTTPClient = &s.client
 	apiClient := datadog.NewAPIClient(configuration)
 	apiClient.Cfg.Host = s.datadogAddress
 	api := datadogV2.NewMetricsApi(apiClient)
 	resp, r, err := api.QueryTimeseriesData(ctx, body)
 
+	// Error responses are errors of the call too, classified by status code, e.g. 429 and 5xx are retryable
+	if r != nil && (r.StatusCode < http.StatusOK || r.StatusCode >= http.StatusMultipleChoices) {
+		return make(map[string][]watcher.Metric), fmt.Errorf("metric resp: %w", watcher.NewHTTPStatusError(r))
+	}
 	if err != nil {
-		return make(map[string][]watcher.Metric), fmt.Errorf("Error when calling `MetricsApi.QueryTimeseriesData`: %v\n", err)
+		return make(map[string][]watcher.Metric), fmt.Errorf("Error when calling `MetricsApi.QueryTimeseriesData`: %w", err)
 	}
 	if r == nil {
 		return make(map[string][]watcher.Metric), errors.New("No response from getting matrix in Datadog API")
 	}
-	if r.StatusCode != http.StatusOK {
-		return make(map[string][]watcher.Metric), fmt.Errorf("received status code %v for metric resp", r.StatusCode)
-	}
 
 	responseContent, _ := json.MarshalIndent(resp, "", "  ")
 	log.Debugf("Response from MetricsApi.QueryTimeseriesData:\n%s\n", responseContent)
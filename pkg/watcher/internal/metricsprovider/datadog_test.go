@@ -1,10 +1,17 @@
 package metricsprovider
 
 import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
 	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
 	"github.com/paypal/load-watcher/pkg/watcher"
 	"github.com/stretchr/testify/assert"
-	"testing"
 )
 
 func TestNewDatadogClient(t *testing.T) {
@@ -268,6 +275,33 @@ func TestDDFetchHostMetrics(t *testing.T) {
 	assert.NotNil(t, metrics["test1"])
 }
 
+func TestDDFetchRateLimited(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	client := datadogClient{
+		client:         *server.Client(),
+		authToken:      "Test",
+		applicationKey: "Test",
+		datadogAddress: strings.TrimPrefix(server.URL, "https://"),
+	}
+	end := time.Now().Unix()
+	window := &watcher.Window{Duration: watcher.FifteenMinutes, Start: end - 15*60, End: end}
+
+	_, err := client.FetchAllHostsMetricsContext(context.Background(), window)
+	var statusErr *watcher.HTTPStatusError
+	assert.True(t, errors.As(err, &statusErr))
+	assert.Equal(t, http.StatusTooManyRequests, statusErr.StatusCode)
+	assert.Equal(t, 30*time.Second, statusErr.RetryAfter)
+	assert.True(t, watcher.IsRetryable(err))
+
+	_, err = client.FetchHostMetricsContext(context.Background(), "test-host", window)
+	assert.True(t, watcher.IsRetryable(err))
+}
+
 func TestDDHealth(t *testing.T) {
 	opts := watcher.MetricsProviderOpts{
 		Name:    watcher.DatadogClientName,
@@ -0,0 +1,219 @@
+/*
+Copyright 2020 PayPal
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metricsprovider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/paypal/load-watcher/pkg/watcher"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	DefaultRecoveryPeriod = 5 * time.Minute
+)
+
+var (
+	_ watcher.PodMetricsProviderClient        = &failoverClient{}
+	_ watcher.SourceProviderClient            = &failoverClient{}
+	_ watcher.ContextMetricsProviderClient    = &failoverClient{}
+	_ watcher.ContextPodMetricsProviderClient = &failoverClient{}
+)
+
+// Fetches metrics from the first available of several backends, e.g. Datadog when SignalFx has an outage.
+// Backends are switched when a fetch fails or the active backend is unhealthy, and preferred backends are tried
+// again once the recovery period elapsed since switching
+type failoverClient struct {
+	// Backends in order of preference, highest first
+	clients        []watcher.ContextMetricsProviderAdapter
+	recoveryPeriod time.Duration
+
+	mutex     sync.Mutex
+	active    int       // Index of the backend fetched from
+	recoverAt time.Time // Time after which backends preferred to the active one are tried again
+	sources   map[string]string
+}
+
+// NewFailoverClient Returns a client fetching from the first available of clients, given in order of preference, highest first.
+// It switches back to a preferred client after recoveryPeriod, DefaultRecoveryPeriod if not positive
+func NewFailoverClient(recoveryPeriod time.Duration, clients ...watcher.MetricsProviderClient) (watcher.MetricsProviderClient, error) {
+	if len(clients) == 0 {
+		return nil, errors.New("failover client needs at least one metrics provider client")
+	}
+	if recoveryPeriod <= 0 {
+		recoveryPeriod = DefaultRecoveryPeriod
+	}
+	adapters := make([]watcher.ContextMetricsProviderAdapter, 0, len(clients))
+	for _, client := range clients {
+		adapters = append(adapters, watcher.WithContext(client))
+	}
+	return &failoverClient{
+		clients:        adapters,
+		recoveryPeriod: recoveryPeriod,
+		sources:        make(map[string]string),
+	}, nil
+}
+
+// Close Closes the clients which are io.Closers
+func (c *failoverClient) Close() error {
+	var errs []error
+	for _, client := range c.clients {
+		if err := client.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Name Returns the name of the active backend
+func (c *failoverClient) Name() string {
+	return c.activeClient().Name()
+}
+
+func (c *failoverClient) FetchHostMetrics(host string, window *watcher.Window) ([]watcher.Metric, error) {
+	return c.FetchHostMetricsContext(context.Background(), host, window)
+}
+
+func (c *failoverClient) FetchHostMetricsContext(ctx context.Context, host string, window *watcher.Window) ([]watcher.Metric, error) {
+	var metrics []watcher.Metric
+	_, err := c.failover(ctx, func(client watcher.ContextMetricsProviderAdapter) error {
+		var err error
+		metrics, err = client.FetchHostMetricsContext(ctx, host, window)
+		return err
+	})
+	return metrics, err
+}
+
+func (c *failoverClient) FetchAllHostsMetrics(window *watcher.Window) (map[string][]watcher.Metric, error) {
+	return c.FetchAllHostsMetricsContext(context.Background(), window)
+}
+
+func (c *failoverClient) FetchAllHostsMetricsContext(ctx context.Context, window *watcher.Window) (map[string][]watcher.Metric, error) {
+	var metrics map[string][]watcher.Metric
+	source, err := c.failover(ctx, func(client watcher.ContextMetricsProviderAdapter) error {
+		var err error
+		metrics, err = client.FetchAllHostsMetricsContext(ctx, window)
+		return err
+	})
+	if source != "" {
+		c.mutex.Lock()
+		c.sources[window.Duration] = source
+		c.mutex.Unlock()
+	}
+	return metrics, err
+}
+
+// FetchAllPodsMetrics Fetches pod metrics from the active backend. Returns nil if it does not support pod metrics
+func (c *failoverClient) FetchAllPodsMetrics(window *watcher.Window) (watcher.PodMetricsMap, error) {
+	return c.FetchAllPodsMetricsContext(context.Background(), window)
+}
+
+func (c *failoverClient) FetchAllPodsMetricsContext(ctx context.Context, window *watcher.Window) (watcher.PodMetricsMap, error) {
+	return c.activeClient().FetchAllPodsMetricsContext(ctx, window)
+}
+
+// FetchSource Returns the name of the backend which supplied the last metrics of the window
+func (c *failoverClient) FetchSource(window *watcher.Window) string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.sources[window.Duration]
+}
+
+// Health Returns 0 if any backend is healthy, switching to it if the active backend is not
+func (c *failoverClient) Health() (int, error) {
+	return c.HealthContext(context.Background())
+}
+
+func (c *failoverClient) HealthContext(ctx context.Context) (int, error) {
+	if _, err := c.failover(ctx, func(client watcher.ContextMetricsProviderAdapter) error { return nil }); err != nil {
+		return -1, err
+	}
+	return 0, nil
+}
+
+func (c *failoverClient) activeClient() watcher.ContextMetricsProviderAdapter {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.clients[c.active]
+}
+
+// Calls fetch with healthy backends in order, starting from the active backend, or from the first backend once
+// the recovery period elapsed, until fetch succeeds. Partial metrics are a success. The backend which succeeded
+// becomes the active one, and its name is returned along with the error of fetch. The errors of all backends
+// are returned if none succeeded. Backends are not failed over once ctx is done
+func (c *failoverClient) failover(ctx context.Context, fetch func(client watcher.ContextMetricsProviderAdapter) error) (string, error) {
+	start := c.firstBackend()
+	var errs []error
+	for i := 0; i < len(c.clients); i++ {
+		if ctx.Err() != nil {
+			return "", errors.Join(append(errs, ctx.Err())...)
+		}
+		index := (start + i) % len(c.clients)
+		client := c.clients[index]
+		err := backendHealth(ctx, client)
+		if err == nil {
+			err = fetch(client)
+		}
+		var partialErr *watcher.PartialMetricsError
+		if err == nil || errors.As(err, &partialErr) {
+			c.activate(index)
+			return client.Name(), err
+		}
+		errs = append(errs, fmt.Errorf("%v: %w", client.Name(), err))
+	}
+	return "", errors.Join(errs...)
+}
+
+// Returns the index of the backend to try first
+func (c *failoverClient) firstBackend() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.active > 0 && !time.Now().Before(c.recoverAt) {
+		return 0
+	}
+	return c.active
+}
+
+// Makes a backend the active one. Preferred backends are tried again after the recovery period, counted from
+// the switch or from the last time they were tried
+func (c *failoverClient) activate(index int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if index != c.active {
+		log.Warnf("switching metrics provider from %v to %v", c.clients[c.active].Name(), c.clients[index].Name())
+	}
+	if index > 0 && (index != c.active || !time.Now().Before(c.recoverAt)) {
+		c.recoverAt = time.Now().Add(c.recoveryPeriod)
+	}
+	c.active = index
+}
+
+// Returns an error if the backend is unhealthy
+func backendHealth(ctx context.Context, client watcher.ContextMetricsProviderAdapter) error {
+	status, err := client.HealthContext(ctx)
+	if status == 0 {
+		return nil
+	}
+	if err == nil {
+		err = fmt.Errorf("unhealthy status %v", status)
+	}
+	return err
+}
@@ -0,0 +1,96 @@
+package metricsprovider
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/paypal/load-watcher/pkg/watcher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFailoverClient(t *testing.T) {
+	signalFx := &staticClient{name: watcher.SignalFxClientName, metrics: map[string][]watcher.Metric{"node-1": {k8sCPU}}}
+	datadog := &staticClient{name: watcher.DatadogClientName, metrics: map[string][]watcher.Metric{"node-1": {promCPU}}}
+	recoveryPeriod := 100 * time.Millisecond
+	client, err := NewFailoverClient(recoveryPeriod, signalFx, datadog)
+	require.Nil(t, err)
+	window := watcher.CurrentFifteenMinuteWindow()
+	sourceClient := client.(watcher.SourceProviderClient)
+
+	assert.Equal(t, "", sourceClient.FetchSource(window))
+	metrics, err := client.FetchAllHostsMetrics(window)
+	require.Nil(t, err)
+	assert.Equal(t, signalFx.metrics, metrics)
+	assert.Equal(t, watcher.SignalFxClientName, sourceClient.FetchSource(window))
+
+	// Failed fetches switch to the next backend
+	signalFx.err = errors.New("outage")
+	metrics, err = client.FetchAllHostsMetrics(window)
+	require.Nil(t, err)
+	assert.Equal(t, datadog.metrics, metrics)
+	assert.Equal(t, watcher.DatadogClientName, sourceClient.FetchSource(window))
+	assert.Equal(t, watcher.DatadogClientName, client.Name())
+
+	// The preferred backend is only tried again after the recovery period
+	signalFx.err = nil
+	metrics, err = client.FetchAllHostsMetrics(window)
+	require.Nil(t, err)
+	assert.Equal(t, datadog.metrics, metrics)
+	time.Sleep(recoveryPeriod)
+	metrics, err = client.FetchAllHostsMetrics(window)
+	require.Nil(t, err)
+	assert.Equal(t, signalFx.metrics, metrics)
+	assert.Equal(t, watcher.SignalFxClientName, sourceClient.FetchSource(window))
+
+	// Unhealthy backends are switched from too, also by health checks
+	signalFx.unhealthy = true
+	status, err := client.Health()
+	assert.Equal(t, 0, status)
+	assert.Nil(t, err)
+	assert.Equal(t, watcher.DatadogClientName, client.Name())
+
+	// Backends which failed before the active one are tried again once the others fail
+	signalFx.unhealthy = false
+	datadog.err = errors.New("outage")
+	metrics, err = client.FetchAllHostsMetrics(window)
+	require.Nil(t, err)
+	assert.Equal(t, signalFx.metrics, metrics)
+
+	signalFx.err = errors.New("outage")
+	_, err = client.FetchAllHostsMetrics(window)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "SignalFx: outage")
+	assert.Contains(t, err.Error(), "Datadog: outage")
+	assert.Equal(t, watcher.SignalFxClientName, sourceClient.FetchSource(window))
+	status, err = client.Health()
+	assert.Equal(t, -1, status)
+	assert.NotNil(t, err)
+
+	_, err = NewFailoverClient(recoveryPeriod)
+	assert.NotNil(t, err)
+}
+
+func TestFailoverClientPartialMetrics(t *testing.T) {
+	prom := &staticClient{name: watcher.PromClientName, metrics: map[string][]watcher.Metric{"node-1": {promCPU}},
+		err: &watcher.PartialMetricsError{Err: errors.New("1 of 2 queries failed")}}
+	client, err := NewFailoverClient(0, partialHealthyClient{prom}, &staticClient{name: watcher.DatadogClientName})
+	require.Nil(t, err)
+
+	// Partial metrics do not switch backends
+	metrics, err := client.FetchAllHostsMetrics(watcher.CurrentFifteenMinuteWindow())
+	var partialErr *watcher.PartialMetricsError
+	assert.ErrorAs(t, err, &partialErr)
+	assert.Equal(t, prom.metrics, metrics)
+	assert.Equal(t, watcher.PromClientName, client.Name())
+}
+
+// Healthy while returning partial metrics
+type partialHealthyClient struct {
+	*staticClient
+}
+
+func (c partialHealthyClient) Health() (int, error) {
+	return 0, nil
+}
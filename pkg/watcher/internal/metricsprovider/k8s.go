@@ -18,16 +18,26 @@ package metricsprovider
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/paypal/load-watcher/pkg/watcher"
 	log "github.com/sirupsen/logrus"
 
+	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/clientcmd"
 	metricsv "k8s.io/metrics/pkg/client/clientset/versioned"
 )
@@ -35,13 +45,31 @@ import (
 var (
 	kubeConfigPresent = false
 	kubeConfigPath    string
+
+	// Informers are shared by the Kubernetes Metrics Server client and node tagger, and stopped once all of them are closed
+	kubeInformersMutex  sync.Mutex
+	kubeInformers       informers.SharedInformerFactory
+	kubeInformersStopCh chan struct{}
+	kubeInformersUsers  int
 )
 
 const (
 	// env variable that provides path to kube config file, if deploying from outside K8s cluster
 	kubeConfig = "KUBE_CONFIG"
+	// env variable that provides the interval at which metrics-server is sampled, e.g. 15s
+	K8sSampleIntervalKey = "K8S_SAMPLE_INTERVAL"
+	// env variable that computes utilization against node allocatable resources instead of capacity if true
+	K8sUseAllocatableKey = "K8S_USE_ALLOCATABLE"
+	// env variable that enables sampling of pod metrics if true
+	K8sPodMetricsKey = "K8S_POD_METRICS"
+
+	DefaultK8sSampleInterval = 15 * time.Second
+	k8sSampleTimeout         = 10 * time.Second
 )
 
+// Time informer caches have to sync in, before clients fail to be created
+var kubeCacheSyncTimeout = time.Minute
+
 func init() {
 	var ok bool
 	kubeConfigPath, ok = os.LookupEnv(kubeConfig)
@@ -50,34 +78,195 @@ func init() {
 	}
 }
 
-// This is a client for K8s provided Metric Server
+var _ watcher.PodMetricsProviderClient = metricsServerClient{}
+
+// This is a client for K8s provided Metric Server. It samples node utilization, and optionally pod usage, periodically,
+// and computes metrics over windows from the samples
 type metricsServerClient struct {
 	// This client fetches node metrics from metric server
-	metricsClientSet *metricsv.Clientset
-	// This client fetches node capacity
-	coreClientSet *kubernetes.Clientset
+	metricsClientSet metricsv.Interface
+	// Lists nodes from an informer cache, for their capacity and labels
+	nodeLister corelisters.NodeLister
+	// Utilization is computed against node allocatable resources if true, capacity otherwise
+	useAllocatable bool
+	// Utilization samples of every node
+	series *usageSeries
+	// Lists pods from an informer cache, for their nodes. Pod metrics are not sampled if nil
+	podLister corelisters.PodLister
+	// Usage samples of every pod, keyed by namespace/name
+	pods *usageSeries
+	// Duration samples are kept for, the largest watched window
+	retention time.Duration
+	// Interval at which metrics-server is sampled
+	sampleInterval time.Duration
+	// Time of the latest successful sample of node metrics, in unix nanoseconds, 0 if none
+	lastSampled *atomic.Int64
+	// Stops sampling and releases the informers, nil if the client does not sample in the background
+	stop func()
 }
 
-func NewMetricsServerClient() (watcher.MetricsProviderClient, error) {
-	var config *rest.Config
-	var err error
+// Returns the in cluster config, or the config of the kube config file if KUBE_CONFIG is set
+func kubeRestConfig() (*rest.Config, error) {
 	kubeConfig := ""
 	if kubeConfigPresent {
 		kubeConfig = kubeConfigPath
 	}
-	config, err = clientcmd.BuildConfigFromFlags("", kubeConfig)
+	return clientcmd.BuildConfigFromFlags("", kubeConfig)
+}
+
+func kubeClientSet() (kubernetes.Interface, error) {
+	config, err := kubeRestConfig()
+	if err != nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(config)
+}
+
+// Returns the informer factory shared by the clients of the process, and the channel to start its informers with.
+// Clients call release once they no longer use the informers, which are stopped when the last client releases them
+func acquireKubeInformers() (factory informers.SharedInformerFactory, stopCh <-chan struct{}, release func(), err error) {
+	kubeInformersMutex.Lock()
+	defer kubeInformersMutex.Unlock()
+	if kubeInformers == nil {
+		clientSet, err := kubeClientSet()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		kubeInformers = informers.NewSharedInformerFactory(clientSet, 0)
+		kubeInformersStopCh = make(chan struct{})
+	}
+	kubeInformersUsers++
+
+	var once sync.Once
+	release = func() {
+		once.Do(func() {
+			kubeInformersMutex.Lock()
+			defer kubeInformersMutex.Unlock()
+			kubeInformersUsers--
+			if kubeInformersUsers == 0 {
+				close(kubeInformersStopCh)
+				kubeInformers.Shutdown()
+				kubeInformers = nil
+			}
+		})
+	}
+	return kubeInformers, kubeInformersStopCh, release, nil
+}
+
+// NewMetricsServerClient Returns a client which samples metrics-server every K8S_SAMPLE_INTERVAL, and watches nodes,
+// until it is closed. Samples are kept for the largest of the windows the metrics are fetched over, DefaultWindows if none
+func NewMetricsServerClient(windows []time.Duration) (watcher.MetricsProviderClient, error) {
+	config, err := kubeRestConfig()
 	if err != nil {
 		return nil, err
 	}
 
+	sampleInterval := DefaultK8sSampleInterval
+	if value, ok := os.LookupEnv(K8sSampleIntervalKey); ok {
+		if sampleInterval, err = time.ParseDuration(value); err != nil || sampleInterval <= 0 {
+			return nil, fmt.Errorf("invalid %v %q, should be a positive duration", K8sSampleIntervalKey, value)
+		}
+	}
+	useAllocatable := false
+	if value, ok := os.LookupEnv(K8sUseAllocatableKey); ok {
+		if useAllocatable, err = strconv.ParseBool(value); err != nil {
+			return nil, fmt.Errorf("invalid %v %q, should be true or false", K8sUseAllocatableKey, value)
+		}
+	}
+	podMetrics := false
+	if value, ok := os.LookupEnv(K8sPodMetricsKey); ok {
+		if podMetrics, err = strconv.ParseBool(value); err != nil {
+			return nil, fmt.Errorf("invalid %v %q, should be true or false", K8sPodMetricsKey, value)
+		}
+	}
+
 	metricsClientSet, err := metricsv.NewForConfig(config)
-	clientSet, err := kubernetes.NewForConfig(config)
 	if err != nil {
 		return nil, err
 	}
+	// Nodes are watched rather than listed on every sample, which matters on large clusters
+	informerFactory, stopCh, release, err := acquireKubeInformers()
+	if err != nil {
+		return nil, err
+	}
+	nodeInformer := informerFactory.Core().V1().Nodes()
+	client := newMetricsServerClient(metricsClientSet, nodeInformer.Lister(), useAllocatable)
+	client.sampleInterval = sampleInterval
+	client.retention = sampleRetention(windows)
+	resources := "nodes"
+	synced := []cache.InformerSynced{nodeInformer.Informer().HasSynced}
+	if podMetrics {
+		podInformer := informerFactory.Core().V1().Pods()
+		client.podLister = podInformer.Lister()
+		resources = "nodes and pods"
+		synced = append(synced, podInformer.Informer().HasSynced)
+	}
+	informerFactory.Start(stopCh)
+	if err = waitForCacheSync(stopCh, resources, synced...); err != nil {
+		release()
+		return nil, err
+	}
+
+	// Sample once before returning, so that the first fetch of the watcher has metrics
+	if err = client.sample(); err != nil {
+		log.Errorf("unable to sample metrics-server: %v", err)
+	}
+	done := make(chan struct{})
+	var once sync.Once
+	client.stop = func() {
+		once.Do(func() {
+			close(done)
+			release()
+		})
+	}
+	go client.sampleLoop(sampleInterval, done)
+	return client, nil
+}
+
+func newMetricsServerClient(metricsClientSet metricsv.Interface, nodeLister corelisters.NodeLister, useAllocatable bool) metricsServerClient {
 	return metricsServerClient{
 		metricsClientSet: metricsClientSet,
-		coreClientSet:    clientSet}, nil
+		nodeLister:       nodeLister,
+		useAllocatable:   useAllocatable,
+		series:           newUsageSeries(),
+		pods:             newUsageSeries(),
+		retention:        sampleRetention(nil),
+		sampleInterval:   DefaultK8sSampleInterval,
+		lastSampled:      new(atomic.Int64),
+	}
+}
+
+// Waits for informer caches of resources to sync, until stopCh is closed or at most kubeCacheSyncTimeout. Fails with an
+// error naming the resources, as caches never sync when listing them is forbidden or the API server is unreachable
+func waitForCacheSync(stopCh <-chan struct{}, resources string, synced ...cache.InformerSynced) error {
+	ctx, cancel := context.WithTimeout(context.Background(), kubeCacheSyncTimeout)
+	defer cancel()
+	go func() {
+		select {
+		case <-stopCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	if !cache.WaitForCacheSync(ctx.Done(), synced...) {
+		return fmt.Errorf("unable to sync informer cache of %v within %v, check that the API server is reachable "+
+			"and that the service account may list and watch %v", resources, kubeCacheSyncTimeout, resources)
+	}
+	return nil
+}
+
+// Returns the duration samples are kept for, the largest of windows, or of DefaultWindows if none
+func sampleRetention(windows []time.Duration) time.Duration {
+	if len(windows) == 0 {
+		windows = watcher.DefaultWindows
+	}
+	var retention time.Duration
+	for _, window := range windows {
+		if window > retention {
+			retention = window
+		}
+	}
+	return retention
 }
 
 func (m metricsServerClient) Name() string {
@@ -85,80 +274,173 @@ func (m metricsServerClient) Name() string {
 }
 
 func (m metricsServerClient) FetchHostMetrics(host string, window *watcher.Window) ([]watcher.Metric, error) {
-	var metrics = []watcher.Metric{}
+	if err := m.checkSampled(); err != nil {
+		return []watcher.Metric{}, err
+	}
+	metrics := m.series.windowMetrics(host, window)
+	if metrics == nil {
+		return []watcher.Metric{}, fmt.Errorf("no samples of host %v in window %v", host, window.Duration)
+	}
+	return metrics, nil
+}
 
-	nodeMetrics, err := m.metricsClientSet.MetricsV1beta1().NodeMetricses().Get(context.TODO(), host, metav1.GetOptions{})
-	if err != nil {
-		return metrics, err
+func (m metricsServerClient) FetchAllHostsMetrics(window *watcher.Window) (map[string][]watcher.Metric, error) {
+	if err := m.checkSampled(); err != nil {
+		return nil, err
 	}
-	var cpuFetchedMetric watcher.Metric
-	var memFetchedMetric watcher.Metric
-	node, err := m.coreClientSet.CoreV1().Nodes().Get(context.Background(), host, metav1.GetOptions{})
-	if err != nil {
-		return metrics, err
+	return m.series.allWindowMetrics(window), nil
+}
+
+// Returns an error unless metrics-server was sampled within the last two sample intervals, so that the Watcher sees
+// an outage of metrics-server as failed fetches, rather than metrics of samples it no longer refreshes
+func (m metricsServerClient) checkSampled() error {
+	lastSampled := m.lastSampled.Load()
+	if lastSampled == 0 {
+		return errors.New("metrics-server has not been sampled yet")
+	}
+	if age := time.Since(time.Unix(0, lastSampled)); age > 2*m.sampleInterval {
+		return fmt.Errorf("metrics-server was last sampled %v ago", age.Truncate(time.Second))
 	}
+	return nil
+}
 
-	// Added CPU latest metric
-	cpuFetchedMetric.Value = float64(100*nodeMetrics.Usage.Cpu().MilliValue()) / float64(node.Status.Capacity.Cpu().MilliValue())
-	cpuFetchedMetric.Type = watcher.CPU
-	cpuFetchedMetric.Operator = watcher.Latest
-	metrics = append(metrics, cpuFetchedMetric)
+// Metrics are fetched from the sampled series in memory, and can't block
+func (m metricsServerClient) FetchHostMetricsContext(_ context.Context, host string, window *watcher.Window) ([]watcher.Metric, error) {
+	return m.FetchHostMetrics(host, window)
+}
 
-	// Added Memory latest metric
-	memFetchedMetric.Value = float64(100*nodeMetrics.Usage.Memory().Value()) / float64(node.Status.Capacity.Memory().Value())
-	memFetchedMetric.Type = watcher.Memory
-	memFetchedMetric.Operator = watcher.Latest
-	metrics = append(metrics, memFetchedMetric)
-	return metrics, nil
+func (m metricsServerClient) FetchAllHostsMetricsContext(_ context.Context, window *watcher.Window) (map[string][]watcher.Metric, error) {
+	return m.FetchAllHostsMetrics(window)
 }
 
-func (m metricsServerClient) FetchAllHostsMetrics(window *watcher.Window) (map[string][]watcher.Metric, error) {
-	metrics := make(map[string][]watcher.Metric)
+// Close Stops sampling metrics-server, and watching nodes unless the informer is used by other clients
+func (m metricsServerClient) Close() error {
+	if m.stop != nil {
+		m.stop()
+	}
+	return nil
+}
 
-	nodeMetricsList, err := m.metricsClientSet.MetricsV1beta1().NodeMetricses().List(context.TODO(), metav1.ListOptions{})
-	if err != nil {
-		return metrics, err
+func (m metricsServerClient) sampleLoop(interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		if err := m.sample(); err != nil {
+			log.Errorf("unable to sample metrics-server: %v", err)
+		}
 	}
-	nodeList, err := m.coreClientSet.CoreV1().Nodes().List(context.Background(), metav1.ListOptions{})
+}
+
+// Adds the current utilization of every node to the series, and drops samples older than the retention
+func (m metricsServerClient) sample() error {
+	ctx, cancel := context.WithTimeout(context.Background(), k8sSampleTimeout)
+	defer cancel()
+
+	nodeMetricsList, err := m.metricsClientSet.MetricsV1beta1().NodeMetricses().List(ctx, metav1.ListOptions{})
 	if err != nil {
-		return metrics, err
+		return err
 	}
 
-	cpuNodeCapacityMap := make(map[string]int64)
-	memNodeCPUCapacityMap := make(map[string]int64)
-	for _, host := range nodeList.Items {
-		cpuNodeCapacityMap[host.Name] = host.Status.Capacity.Cpu().MilliValue()
-		memNodeCPUCapacityMap[host.Name] = host.Status.Capacity.Memory().Value()
-	}
 	for _, host := range nodeMetricsList.Items {
-		var cpuFetchedMetric watcher.Metric
-		cpuFetchedMetric.Type = watcher.CPU
-		cpuFetchedMetric.Operator = watcher.Latest
-		if _, ok := cpuNodeCapacityMap[host.Name]; !ok {
-			log.Errorf("unable to find host %v in node list caching cpu capacity", host.Name)
+		node, err := m.nodeLister.Get(host.Name)
+		if err != nil {
+			log.Errorf("unable to find host %v in node cache: %v", host.Name, err)
+			continue
+		}
+		resources := m.nodeResources(node)
+		cpuCapacity := resources.Cpu().MilliValue()
+		if cpuCapacity == 0 {
+			log.Errorf("unable to find cpu capacity of host %v", host.Name)
 			continue
 		}
+		memCapacity := resources.Memory().Value()
+		if memCapacity == 0 {
+			log.Errorf("unable to find memory capacity of host %v", host.Name)
+			continue
+		}
+		m.series.add(host.Name, usageSample{
+			timestamp: host.Timestamp.Unix(),
+			cpu:       float64(100*host.Usage.Cpu().MilliValue()) / float64(cpuCapacity),
+			memory:    float64(100*host.Usage.Memory().Value()) / float64(memCapacity),
+		})
+	}
 
-		cpuFetchedMetric.Value = float64(100*host.Usage.Cpu().MilliValue()) / float64(cpuNodeCapacityMap[host.Name])
-		metrics[host.Name] = append(metrics[host.Name], cpuFetchedMetric)
+	m.series.prune(time.Now().Add(-m.retention).Unix())
+	m.lastSampled.Store(time.Now().UnixNano())
 
-		var memFetchedMetric watcher.Metric
-		memFetchedMetric.Type = watcher.Memory
-		memFetchedMetric.Operator = watcher.Latest
-		if _, ok := memNodeCPUCapacityMap[host.Name]; !ok {
-			log.Errorf("unable to find host %v in node list caching memory capacity", host.Name)
-			continue
+	if m.podLister != nil {
+		return m.samplePods(ctx)
+	}
+	return nil
+}
+
+// Adds the current CPU usage in cores and memory usage in bytes of every pod to the pod series
+func (m metricsServerClient) samplePods(ctx context.Context) error {
+	podMetricsList, err := m.metricsClientSet.MetricsV1beta1().PodMetricses(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for _, pod := range podMetricsList.Items {
+		var cpu, memory float64
+		for _, container := range pod.Containers {
+			cpu += float64(container.Usage.Cpu().MilliValue()) / 1000
+			memory += float64(container.Usage.Memory().Value())
 		}
-		memFetchedMetric.Value = float64(100*host.Usage.Memory().Value()) / float64(memNodeCPUCapacityMap[host.Name])
-		metrics[host.Name] = append(metrics[host.Name], memFetchedMetric)
+		m.pods.add(watcher.PodKey(pod.Namespace, pod.Name), usageSample{
+			timestamp: pod.Timestamp.Unix(),
+			cpu:       cpu,
+			memory:    memory,
+		})
 	}
+	m.pods.prune(time.Now().Add(-m.retention).Unix())
+	return nil
+}
 
-	return metrics, nil
+// FetchAllPodsMetrics Returns nil unless pod metrics are sampled
+func (m metricsServerClient) FetchAllPodsMetrics(window *watcher.Window) (watcher.PodMetricsMap, error) {
+	if m.podLister == nil {
+		return nil, nil
+	}
+	if err := m.checkSampled(); err != nil {
+		return nil, err
+	}
+	podMetricsMap := make(watcher.PodMetricsMap)
+	for key, metrics := range m.pods.allWindowMetrics(window) {
+		namespace, name, _ := strings.Cut(key, "/")
+		podMetrics := watcher.PodMetrics{Namespace: namespace, Name: name, Metrics: metrics}
+		// Pods deleted since they were sampled have no node
+		if pod, err := m.podLister.Pods(namespace).Get(name); err == nil {
+			podMetrics.Node = pod.Spec.NodeName
+		}
+		podMetricsMap[key] = podMetrics
+	}
+	return podMetricsMap, nil
+}
+
+func (m metricsServerClient) FetchAllPodsMetricsContext(_ context.Context, window *watcher.Window) (watcher.PodMetricsMap, error) {
+	return m.FetchAllPodsMetrics(window)
+}
+
+// Returns the resources utilization is computed against, allocatable or capacity
+func (m metricsServerClient) nodeResources(node *v1.Node) v1.ResourceList {
+	if m.useAllocatable {
+		return node.Status.Allocatable
+	}
+	return node.Status.Capacity
 }
 
 func (m metricsServerClient) Health() (int, error) {
+	return m.HealthContext(context.Background())
+}
+
+func (m metricsServerClient) HealthContext(ctx context.Context) (int, error) {
 	var status int
-	m.metricsClientSet.RESTClient().Verb("HEAD").Do(context.Background()).StatusCode(&status)
+	m.metricsClientSet.Discovery().RESTClient().Verb("HEAD").Do(ctx).StatusCode(&status)
 	if status != http.StatusOK {
 		return -1, fmt.Errorf("received response status code: %v", status)
 	}
@@ -0,0 +1,132 @@
+/*
+Copyright 2020 PayPal
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metricsprovider
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+
+	"github.com/paypal/load-watcher/pkg/watcher"
+
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	discoverylisters "k8s.io/client-go/listers/discovery/v1"
+)
+
+// Resolves a Kubernetes Service to the addresses of its ready endpoints, from its EndpointSlices
+type kubernetesServiceResolver struct {
+	lister discoverylisters.EndpointSliceNamespaceLister
+	// Labels of the EndpointSlices of the Service
+	selector labels.Selector
+	service  string
+	// Name or number of the endpoint port, the first port of the EndpointSlices if empty
+	port   string
+	scheme string
+}
+
+// NewKubernetesServiceResolver Returns a resolver of the ready endpoints of the Service name in namespace to base URLs
+// with scheme, e.g. http://10.0.0.1:2020. Only the EndpointSlices of the Service are watched, for the lifetime of the process
+func NewKubernetesServiceResolver(namespace string, name string, port string, scheme string) (watcher.EndpointResolver, error) {
+	clientSet, err := kubeClientSet()
+	if err != nil {
+		return nil, err
+	}
+	selector := labels.SelectorFromSet(labels.Set{discoveryv1.LabelServiceName: name})
+	informerFactory := informers.NewSharedInformerFactoryWithOptions(clientSet, 0,
+		informers.WithNamespace(namespace),
+		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.LabelSelector = selector.String()
+		}))
+	endpointSliceInformer := informerFactory.Discovery().V1().EndpointSlices()
+	synced := endpointSliceInformer.Informer().HasSynced
+	// Service clients are not closed, so the informer runs for the lifetime of the process
+	stopCh := make(chan struct{})
+	informerFactory.Start(stopCh)
+	if err = waitForCacheSync(stopCh, "endpointslices", synced); err != nil {
+		close(stopCh)
+		return nil, err
+	}
+	return newKubernetesServiceResolver(endpointSliceInformer.Lister().EndpointSlices(namespace), name, port, scheme), nil
+}
+
+func newKubernetesServiceResolver(lister discoverylisters.EndpointSliceNamespaceLister, name string, port string, scheme string) kubernetesServiceResolver {
+	if scheme == "" {
+		scheme = "http"
+	}
+	return kubernetesServiceResolver{
+		lister:   lister,
+		selector: labels.SelectorFromSet(labels.Set{discoveryv1.LabelServiceName: name}),
+		service:  name,
+		port:     port,
+		scheme:   scheme,
+	}
+}
+
+// Endpoints Returns the sorted base URLs of the ready endpoints of the Service
+func (r kubernetesServiceResolver) Endpoints() ([]string, error) {
+	slices, err := r.lister.List(r.selector)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var endpoints []string
+	for _, slice := range slices {
+		port, ok := r.slicePort(slice)
+		if !ok {
+			continue
+		}
+		for _, endpoint := range slice.Endpoints {
+			// Endpoints of unknown readiness are ready
+			if endpoint.Conditions.Ready != nil && !*endpoint.Conditions.Ready {
+				continue
+			}
+			for _, address := range endpoint.Addresses {
+				url := r.scheme + "://" + net.JoinHostPort(address, strconv.Itoa(int(port)))
+				if !seen[url] {
+					seen[url] = true
+					endpoints = append(endpoints, url)
+				}
+			}
+		}
+	}
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("no ready endpoints of service %v", r.service)
+	}
+	sort.Strings(endpoints)
+	return endpoints, nil
+}
+
+// Returns the port of the endpoints of a slice selected by name or number, false if the slice has no such port
+func (r kubernetesServiceResolver) slicePort(slice *discoveryv1.EndpointSlice) (int32, bool) {
+	for _, port := range slice.Ports {
+		if port.Port == nil {
+			continue
+		}
+		if r.port == "" || r.port == strconv.Itoa(int(*port.Port)) || (port.Name != nil && r.port == *port.Name) {
+			return *port.Port, true
+		}
+	}
+	// Slices of Services without ports, e.g. headless ones, are reached on the port if it is a number
+	if number, err := strconv.Atoi(r.port); err == nil && len(slice.Ports) == 0 {
+		return int32(number), true
+	}
+	return 0, false
+}
@@ -0,0 +1,150 @@
+/*
+Copyright 2020 PayPal
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metricsprovider
+
+import (
+	"sort"
+
+	"github.com/paypal/load-watcher/pkg/watcher"
+	log "github.com/sirupsen/logrus"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	corelisters "k8s.io/client-go/listers/core/v1"
+)
+
+// Well-known node labels of node metadata, in order of precedence
+var (
+	zoneLabels         = []string{v1.LabelTopologyZone, v1.LabelFailureDomainBetaZone}
+	regionLabels       = []string{v1.LabelTopologyRegion, v1.LabelFailureDomainBetaRegion}
+	instanceTypeLabels = []string{v1.LabelInstanceTypeStable, v1.LabelInstanceType}
+	nodePoolLabels     = []string{
+		"cloud.google.com/gke-nodepool",
+		"eks.amazonaws.com/nodegroup",
+		"kubernetes.azure.com/agentpool",
+		"karpenter.sh/nodepool",
+	}
+)
+
+// Tags nodes from the Kubernetes node objects, for any metrics provider whose hosts are node names
+type kubernetesNodeTagger struct {
+	nodeLister corelisters.NodeLister
+	labels     []string
+	taints     []string
+	// Releases the node informer, nil if the tagger does not own a reference to it
+	release func()
+}
+
+// NewKubernetesNodeTagger Returns a node tagger which watches nodes until it is closed, sharing the informer of the
+// Kubernetes Metrics Server client
+func NewKubernetesNodeTagger(opts watcher.NodeTaggerOpts) (watcher.NodeTagger, error) {
+	informerFactory, stopCh, release, err := acquireKubeInformers()
+	if err != nil {
+		return nil, err
+	}
+	nodeInformer := informerFactory.Core().V1().Nodes()
+	synced := nodeInformer.Informer().HasSynced
+	informerFactory.Start(stopCh)
+	if err = waitForCacheSync(stopCh, "nodes", synced); err != nil {
+		release()
+		return nil, err
+	}
+	tagger := newKubernetesNodeTagger(nodeInformer.Lister(), opts)
+	tagger.release = release
+	return tagger, nil
+}
+
+// Close Stops watching nodes, unless the informer is used by other clients
+func (t kubernetesNodeTagger) Close() error {
+	if t.release != nil {
+		t.release()
+	}
+	return nil
+}
+
+func newKubernetesNodeTagger(nodeLister corelisters.NodeLister, opts watcher.NodeTaggerOpts) kubernetesNodeTagger {
+	return kubernetesNodeTagger{nodeLister: nodeLister, labels: opts.Labels, taints: opts.Taints}
+}
+
+func (t kubernetesNodeTagger) NodeInfo(host string) (watcher.NodeInfo, bool) {
+	node, err := t.nodeLister.Get(host)
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			log.Errorf("unable to get node %v: %v", host, err)
+		}
+		return watcher.NodeInfo{}, false
+	}
+	return watcher.NodeInfo{
+		Tags: watcher.Tags{
+			Labels: selectedLabels(node.Labels, t.labels),
+			Taints: selectedTaints(node.Spec.Taints, t.taints),
+		},
+		Metadata: watcher.Metadata{
+			Zone:         firstLabel(node.Labels, zoneLabels),
+			Region:       firstLabel(node.Labels, regionLabels),
+			InstanceType: firstLabel(node.Labels, instanceTypeLabels),
+			NodePool:     firstLabel(node.Labels, nodePoolLabels),
+		},
+		Labels: node.Labels,
+	}, true
+}
+
+// Returns the value of the first of keys present in labels
+func firstLabel(labels map[string]string, keys []string) string {
+	for _, key := range keys {
+		if value, ok := labels[key]; ok {
+			return value
+		}
+	}
+	return ""
+}
+
+// Returns the labels having one of keys, all labels if keys contains "*", nil if none
+func selectedLabels(labels map[string]string, keys []string) map[string]string {
+	var selected map[string]string
+	for key, value := range labels {
+		if !selectsKey(keys, key) {
+			continue
+		}
+		if selected == nil {
+			selected = make(map[string]string)
+		}
+		selected[key] = value
+	}
+	return selected
+}
+
+// Returns the taints having one of keys as key=value:Effect, sorted, all taints if keys contains "*"
+func selectedTaints(taints []v1.Taint, keys []string) []string {
+	var selected []string
+	for _, taint := range taints {
+		if selectsKey(keys, taint.Key) {
+			selected = append(selected, taint.ToString())
+		}
+	}
+	sort.Strings(selected)
+	return selected
+}
+
+func selectsKey(keys []string, key string) bool {
+	for _, k := range keys {
+		if k == "*" || k == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2020 PayPal
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metricsprovider
+
+import (
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/paypal/load-watcher/pkg/watcher"
+)
+
+// Percentile operators computed over windows, with their percentile
+var seriesPercentiles = []struct {
+	operator   string
+	percentile float64
+}{
+	{watcher.P50, 50},
+	{watcher.P90, 90},
+	{watcher.P95, 95},
+	{watcher.P99, 99},
+}
+
+// CPU and memory usage at a point in time, in percent of node resources for nodes, absolute for pods
+type usageSample struct {
+	timestamp int64 // Unix seconds
+	cpu       float64
+	memory    float64
+}
+
+// In memory time series of usage samples of nodes or pods, in increasing time order per key
+type usageSeries struct {
+	mutex   sync.RWMutex
+	samples map[string][]usageSample
+}
+
+func newUsageSeries() *usageSeries {
+	return &usageSeries{samples: make(map[string][]usageSample)}
+}
+
+// Appends a sample of a key, unless it is not newer than the latest sample of the key,
+// e.g. when metrics-server has not scraped the node again since the previous sample
+func (s *usageSeries) add(key string, sample usageSample) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	samples := s.samples[key]
+	if len(samples) > 0 && sample.timestamp <= samples[len(samples)-1].timestamp {
+		return
+	}
+	s.samples[key] = append(samples, sample)
+}
+
+// Drops samples older than oldest, and keys left without samples
+func (s *usageSeries) prune(oldest int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for key, samples := range s.samples {
+		i := sort.Search(len(samples), func(i int) bool { return samples[i].timestamp >= oldest })
+		if i == len(samples) {
+			delete(s.samples, key)
+			continue
+		}
+		s.samples[key] = append([]usageSample(nil), samples[i:]...)
+	}
+}
+
+// Returns the metrics of a key over the window, nil if the key has no samples in the window
+func (s *usageSeries) windowMetrics(key string, window *watcher.Window) []watcher.Metric {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return seriesMetrics(s.samples[key], window)
+}
+
+// Returns the metrics of all keys having samples in the window
+func (s *usageSeries) allWindowMetrics(window *watcher.Window) map[string][]watcher.Metric {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	metrics := make(map[string][]watcher.Metric, len(s.samples))
+	for key, samples := range s.samples {
+		if keyMetrics := seriesMetrics(samples, window); keyMetrics != nil {
+			metrics[key] = keyMetrics
+		}
+	}
+	return metrics
+}
+
+// Computes the Latest, AVG, STD, MAX and percentile metrics of CPU and memory over the samples in the window
+func seriesMetrics(samples []usageSample, window *watcher.Window) []watcher.Metric {
+	start := sort.Search(len(samples), func(i int) bool { return samples[i].timestamp >= window.Start })
+	end := sort.Search(len(samples), func(i int) bool { return samples[i].timestamp > window.End })
+	if start >= end {
+		return nil
+	}
+	cpu := make([]float64, 0, end-start)
+	memory := make([]float64, 0, end-start)
+	for _, sample := range samples[start:end] {
+		cpu = append(cpu, sample.cpu)
+		memory = append(memory, sample.memory)
+	}
+	metrics := seriesStats(cpu, watcher.CPU, window.Duration)
+	return append(metrics, seriesStats(memory, watcher.Memory, window.Duration)...)
+}
+
+func seriesStats(values []float64, metricType string, rollup string) []watcher.Metric {
+	metric := func(operator string, value float64) watcher.Metric {
+		return watcher.Metric{Type: metricType, Operator: operator, Rollup: rollup, Value: value}
+	}
+
+	var sum, max float64 = 0, values[0]
+	for _, value := range values {
+		sum += value
+		max = math.Max(max, value)
+	}
+	avg := sum / float64(len(values))
+	var squares float64
+	for _, value := range values {
+		squares += (value - avg) * (value - avg)
+	}
+
+	metrics := []watcher.Metric{
+		metric(watcher.Latest, values[len(values)-1]),
+		metric(watcher.Average, avg),
+		// Population standard deviation, like stddev_over_time of Prometheus
+		metric(watcher.Std, math.Sqrt(squares/float64(len(values)))),
+		metric(watcher.Max, max),
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	for _, p := range seriesPercentiles {
+		metrics = append(metrics, metric(p.operator, percentile(sorted, p.percentile)))
+	}
+	return metrics
+}
+
+// Returns the nearest-rank percentile of sorted values
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
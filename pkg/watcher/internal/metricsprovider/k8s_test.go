@@ -0,0 +1,398 @@
+package metricsprovider
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/paypal/load-watcher/pkg/watcher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	discoverylisters "k8s.io/client-go/listers/discovery/v1"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+	metricsfake "k8s.io/metrics/pkg/client/clientset/versioned/fake"
+)
+
+func newTestNode(name string, cpu string, memory string) *v1.Node {
+	resources := v1.ResourceList{
+		v1.ResourceCPU:    resource.MustParse(cpu),
+		v1.ResourceMemory: resource.MustParse(memory),
+	}
+	return &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status:     v1.NodeStatus{Capacity: resources, Allocatable: resources.DeepCopy()},
+	}
+}
+
+// Returns a lister of the nodes, as cached by an informer
+func newTestNodeLister(t *testing.T, nodes ...*v1.Node) corelisters.NodeLister {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, node := range nodes {
+		require.Nil(t, indexer.Add(node))
+	}
+	return corelisters.NewNodeLister(indexer)
+}
+
+func newTestNodeMetrics(name string, timestamp time.Time, cpu string, memory string) *metricsv1beta1.NodeMetrics {
+	return &metricsv1beta1.NodeMetrics{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Timestamp:  metav1.NewTime(timestamp),
+		Usage: v1.ResourceList{
+			v1.ResourceCPU:    resource.MustParse(cpu),
+			v1.ResourceMemory: resource.MustParse(memory),
+		},
+	}
+}
+
+func TestK8sSampledWindowMetrics(t *testing.T) {
+	now := time.Now()
+	metricsClientSet := metricsfake.NewSimpleClientset()
+	client := newMetricsServerClient(metricsClientSet, newTestNodeLister(t,
+		newTestNode("node-1", "4", "8Gi"),
+		newTestNode("node-2", "2", "4Gi"),
+	), false)
+
+	// node-1 uses 1, 2, 3 and 4 CPUs and 2Gi of memory over the last 4 minutes
+	for i, cpu := range []string{"1", "2", "3", "4"} {
+		timestamp := now.Add(time.Duration(i-3) * time.Minute)
+		metricsClientSet.Fake.PrependReactor("list", "nodes", nodeMetricsListReactor(
+			newTestNodeMetrics("node-1", timestamp, cpu, "2Gi"),
+			newTestNodeMetrics("node-2", timestamp, "1", "1Gi"),
+		))
+		require.Nil(t, client.sample())
+	}
+	// Samples which metrics-server did not refresh are not added again
+	require.Nil(t, client.sample())
+
+	metrics, err := client.FetchAllHostsMetrics(watcher.CurrentWindow(15 * time.Minute))
+	require.Nil(t, err)
+	require.Len(t, metrics, 2)
+	cpu := metricsByOperator(metrics["node-1"], watcher.CPU)
+	assert.Equal(t, float64(100), cpu[watcher.Latest])
+	assert.Equal(t, 62.5, cpu[watcher.Average])
+	assert.InDelta(t, 27.95, cpu[watcher.Std], 0.01)
+	assert.Equal(t, float64(100), cpu[watcher.Max])
+	assert.Equal(t, float64(50), cpu[watcher.P50])
+	assert.Equal(t, float64(100), cpu[watcher.P99])
+	memory := metricsByOperator(metrics["node-1"], watcher.Memory)
+	assert.Equal(t, float64(25), memory[watcher.Average])
+	assert.Equal(t, float64(0), memory[watcher.Std])
+	for _, metric := range metrics["node-1"] {
+		assert.Equal(t, watcher.FifteenMinutes, metric.Rollup)
+	}
+
+	// Only the samples of the last minute and a half are in a 90s window
+	hostMetrics, err := client.FetchHostMetrics("node-1", watcher.CurrentWindow(90*time.Second))
+	require.Nil(t, err)
+	cpu = metricsByOperator(hostMetrics, watcher.CPU)
+	assert.Equal(t, 87.5, cpu[watcher.Average])
+	assert.Equal(t, float64(75), cpu[watcher.P50])
+
+	_, err = client.FetchHostMetrics("node-3", watcher.CurrentWindow(90*time.Second))
+	assert.NotNil(t, err)
+}
+
+func TestK8sUtilizationOfAllocatable(t *testing.T) {
+	node := newTestNode("node-1", "4", "8Gi")
+	node.Status.Allocatable[v1.ResourceCPU] = resource.MustParse("2")
+	node.Status.Allocatable[v1.ResourceMemory] = resource.MustParse("4Gi")
+	nodeLister := newTestNodeLister(t, node, newTestNode("node-2", "2", "4Gi"))
+	metricsClientSet := metricsfake.NewSimpleClientset()
+	metricsClientSet.Fake.PrependReactor("list", "nodes", nodeMetricsListReactor(
+		newTestNodeMetrics("node-1", time.Now(), "1", "2Gi"),
+		// Nodes missing from the node cache are skipped
+		newTestNodeMetrics("node-3", time.Now(), "1", "2Gi"),
+	))
+
+	for useAllocatable, expected := range map[bool]float64{false: 25, true: 50} {
+		client := newMetricsServerClient(metricsClientSet, nodeLister, useAllocatable)
+		require.Nil(t, client.sample())
+		metrics, err := client.FetchAllHostsMetrics(watcher.CurrentFifteenMinuteWindow())
+		require.Nil(t, err)
+		require.Len(t, metrics, 1)
+		assert.Equal(t, expected, metricsByOperator(metrics["node-1"], watcher.CPU)[watcher.Latest])
+		assert.Equal(t, expected, metricsByOperator(metrics["node-1"], watcher.Memory)[watcher.Latest])
+	}
+}
+
+func TestK8sPodMetrics(t *testing.T) {
+	podMetrics := func(namespace string, name string, timestamp time.Time, cpu ...string) *metricsv1beta1.PodMetrics {
+		m := &metricsv1beta1.PodMetrics{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}, Timestamp: metav1.NewTime(timestamp)}
+		for _, c := range cpu {
+			m.Containers = append(m.Containers, metricsv1beta1.ContainerMetrics{Usage: v1.ResourceList{
+				v1.ResourceCPU:    resource.MustParse(c),
+				v1.ResourceMemory: resource.MustParse("1Mi"),
+			}})
+		}
+		return m
+	}
+	podIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	require.Nil(t, podIndexer.Add(&v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web-1"}, Spec: v1.PodSpec{NodeName: "node-1"}}))
+
+	metricsClientSet := metricsfake.NewSimpleClientset()
+	client := newMetricsServerClient(metricsClientSet, newTestNodeLister(t), false)
+	pods, err := client.FetchAllPodsMetrics(watcher.CurrentFifteenMinuteWindow())
+	require.Nil(t, err)
+	assert.Nil(t, pods)
+
+	client.podLister = corelisters.NewPodLister(podIndexer)
+	now := time.Now()
+	for i, cpu := range []string{"100m", "300m"} {
+		timestamp := now.Add(time.Duration(i-1) * time.Minute)
+		metricsClientSet.Fake.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, &metricsv1beta1.PodMetricsList{Items: []metricsv1beta1.PodMetrics{
+				*podMetrics("default", "web-1", timestamp, cpu, cpu),
+				*podMetrics("batch", "job-1", timestamp, "1"),
+			}}, nil
+		})
+		require.Nil(t, client.sample())
+	}
+
+	pods, err = client.FetchAllPodsMetrics(watcher.CurrentFifteenMinuteWindow())
+	require.Nil(t, err)
+	require.Len(t, pods, 2)
+	web := pods[watcher.PodKey("default", "web-1")]
+	assert.Equal(t, "default", web.Namespace)
+	assert.Equal(t, "web-1", web.Name)
+	assert.Equal(t, "node-1", web.Node)
+	cpu := metricsByOperator(web.Metrics, watcher.CPU)
+	assert.InDelta(t, 0.6, cpu[watcher.Latest], 1e-9)
+	assert.InDelta(t, 0.4, cpu[watcher.Average], 1e-9)
+	assert.Equal(t, float64(2<<20), metricsByOperator(web.Metrics, watcher.Memory)[watcher.Latest])
+	// Pods missing from the pod cache have no node
+	assert.Equal(t, "", pods[watcher.PodKey("batch", "job-1")].Node)
+}
+
+func TestKubernetesNodeTagger(t *testing.T) {
+	node := newTestNode("node-1", "4", "8Gi")
+	node.Labels = map[string]string{
+		v1.LabelTopologyZone:            "us-east-1a",
+		v1.LabelFailureDomainBetaZone:   "us-east-1b",
+		v1.LabelFailureDomainBetaRegion: "us-east-1",
+		v1.LabelInstanceType:            "m5.xlarge",
+		"eks.amazonaws.com/nodegroup":   "web",
+		"team":                          "payments",
+	}
+	node.Spec.Taints = []v1.Taint{
+		{Key: "dedicated", Value: "web", Effect: v1.TaintEffectNoSchedule},
+		{Key: "gpu", Effect: v1.TaintEffectNoExecute},
+	}
+	nodeLister := newTestNodeLister(t, node)
+
+	tagger := newKubernetesNodeTagger(nodeLister, watcher.NodeTaggerOpts{Labels: []string{"team", "missing"}, Taints: []string{"*"}})
+	info, ok := tagger.NodeInfo("node-1")
+	require.True(t, ok)
+	assert.Equal(t, watcher.Metadata{Zone: "us-east-1a", Region: "us-east-1", InstanceType: "m5.xlarge", NodePool: "web"}, info.Metadata)
+	assert.Equal(t, watcher.Tags{
+		Labels: map[string]string{"team": "payments"},
+		Taints: []string{"dedicated=web:NoSchedule", "gpu:NoExecute"},
+	}, info.Tags)
+	assert.Equal(t, node.Labels, info.Labels)
+
+	// Nothing is copied to tags unless selected
+	info, ok = newKubernetesNodeTagger(nodeLister, watcher.NodeTaggerOpts{Taints: []string{"gpu"}}).NodeInfo("node-1")
+	require.True(t, ok)
+	assert.Equal(t, watcher.Tags{Taints: []string{"gpu:NoExecute"}}, info.Tags)
+
+	_, ok = tagger.NodeInfo("node-2")
+	assert.False(t, ok)
+}
+
+func TestKubernetesServiceResolver(t *testing.T) {
+	ready, notReady := true, false
+	port := func(name string, number int32) discoveryv1.EndpointPort {
+		return discoveryv1.EndpointPort{Name: &name, Port: &number}
+	}
+	endpointSlice := func(name string, service string, ports []discoveryv1.EndpointPort, endpoints ...discoveryv1.Endpoint) *discoveryv1.EndpointSlice {
+		return &discoveryv1.EndpointSlice{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "monitoring", Name: name, Labels: map[string]string{discoveryv1.LabelServiceName: service}},
+			Ports:      ports,
+			Endpoints:  endpoints,
+		}
+	}
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, slice := range []*discoveryv1.EndpointSlice{
+		endpointSlice("load-watcher-ipv4", "load-watcher", []discoveryv1.EndpointPort{port("metrics", 9090), port("http", 2020)},
+			discoveryv1.Endpoint{Addresses: []string{"10.0.0.2"}, Conditions: discoveryv1.EndpointConditions{Ready: &ready}},
+			discoveryv1.Endpoint{Addresses: []string{"10.0.0.1"}},
+			discoveryv1.Endpoint{Addresses: []string{"10.0.0.3"}, Conditions: discoveryv1.EndpointConditions{Ready: &notReady}},
+		),
+		endpointSlice("load-watcher-ipv6", "load-watcher", []discoveryv1.EndpointPort{port("http", 2020)},
+			discoveryv1.Endpoint{Addresses: []string{"fd00::1"}},
+		),
+		endpointSlice("other", "other", []discoveryv1.EndpointPort{port("http", 2020)},
+			discoveryv1.Endpoint{Addresses: []string{"10.0.1.1"}},
+		),
+	} {
+		require.Nil(t, indexer.Add(slice))
+	}
+	lister := discoverylisters.NewEndpointSliceLister(indexer).EndpointSlices("monitoring")
+
+	endpoints, err := newKubernetesServiceResolver(lister, "load-watcher", "http", "").Endpoints()
+	require.Nil(t, err)
+	assert.Equal(t, []string{"http://10.0.0.1:2020", "http://10.0.0.2:2020", "http://[fd00::1]:2020"}, endpoints)
+
+	// Ports are selected by number too, and the first port is used by default
+	endpoints, err = newKubernetesServiceResolver(lister, "load-watcher", "9090", "https").Endpoints()
+	require.Nil(t, err)
+	assert.Equal(t, []string{"https://10.0.0.1:9090", "https://10.0.0.2:9090"}, endpoints)
+	endpoints, err = newKubernetesServiceResolver(lister, "load-watcher", "", "").Endpoints()
+	require.Nil(t, err)
+	assert.Equal(t, []string{"http://10.0.0.1:9090", "http://10.0.0.2:9090", "http://[fd00::1]:2020"}, endpoints)
+
+	_, err = newKubernetesServiceResolver(lister, "load-watcher", "grpc", "").Endpoints()
+	assert.NotNil(t, err)
+	_, err = newKubernetesServiceResolver(lister, "missing", "", "").Endpoints()
+	assert.NotNil(t, err)
+}
+
+func TestK8sSamplesPruned(t *testing.T) {
+	series := newUsageSeries()
+	now := time.Now().Unix()
+	series.add("node-1", usageSample{timestamp: now - 3600, cpu: 10})
+	series.add("node-1", usageSample{timestamp: now - 60, cpu: 20})
+	series.add("node-2", usageSample{timestamp: now - 3600, cpu: 30})
+	series.prune(now - 900)
+
+	assert.Len(t, series.samples, 1)
+	assert.Equal(t, []usageSample{{timestamp: now - 60, cpu: 20}}, series.samples["node-1"])
+}
+
+func TestK8sSampleFailures(t *testing.T) {
+	metricsClientSet := metricsfake.NewSimpleClientset()
+	client := newMetricsServerClient(metricsClientSet, newTestNodeLister(t, newTestNode("node-1", "4", "8Gi")), false)
+	unavailable := func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("metrics-server unavailable")
+	}
+	window := watcher.CurrentWindow(15 * time.Minute)
+
+	// Nothing is fetched before metrics-server is sampled
+	metricsClientSet.Fake.PrependReactor("list", "nodes", unavailable)
+	assert.NotNil(t, client.sample())
+	_, err := client.FetchAllHostsMetrics(window)
+	assert.NotNil(t, err)
+
+	metricsClientSet.Fake.PrependReactor("list", "nodes", nodeMetricsListReactor(
+		newTestNodeMetrics("node-1", time.Now(), "1", "2Gi"),
+	))
+	require.Nil(t, client.sample())
+	metrics, err := client.FetchAllHostsMetrics(window)
+	require.Nil(t, err)
+	assert.Len(t, metrics, 1)
+
+	// Samples are kept, but not served once metrics-server fails for more than two sample intervals
+	metricsClientSet.Fake.PrependReactor("list", "nodes", unavailable)
+	assert.NotNil(t, client.sample())
+	_, err = client.FetchAllHostsMetrics(window)
+	assert.Nil(t, err)
+	client.lastSampled.Store(time.Now().Add(-3 * client.sampleInterval).UnixNano())
+	_, err = client.FetchAllHostsMetrics(window)
+	assert.NotNil(t, err)
+	_, err = client.FetchHostMetrics("node-1", window)
+	assert.NotNil(t, err)
+}
+
+func TestK8sClientClose(t *testing.T) {
+	metricsClientSet := metricsfake.NewSimpleClientset()
+	client := newMetricsServerClient(metricsClientSet, newTestNodeLister(t), false)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	client.stop = func() { close(done) }
+	go func() {
+		client.sampleLoop(time.Millisecond, done)
+		close(stopped)
+	}()
+
+	// Closing a composite client closes its clients
+	composite, err := NewCompositeClient(client)
+	require.Nil(t, err)
+	require.Nil(t, composite.(io.Closer).Close())
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("sampling did not stop on Close")
+	}
+}
+
+func TestKubeInformersReleased(t *testing.T) {
+	kubeInformersMutex.Lock()
+	kubeInformers = informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0)
+	kubeInformersStopCh = make(chan struct{})
+	kubeInformersMutex.Unlock()
+
+	factory, stopCh, release, err := acquireKubeInformers()
+	require.Nil(t, err)
+	sharedFactory, _, releaseShared, err := acquireKubeInformers()
+	require.Nil(t, err)
+	assert.Equal(t, factory, sharedFactory)
+	factory.Core().V1().Nodes().Informer()
+	factory.Start(stopCh)
+
+	// Informers run until the last client releases them, once
+	release()
+	release()
+	select {
+	case <-stopCh:
+		t.Fatal("informers stopped while used")
+	default:
+	}
+	releaseShared()
+	<-stopCh
+	assert.Nil(t, kubeInformers)
+}
+
+func TestK8sSampleRetention(t *testing.T) {
+	assert.Equal(t, 15*time.Minute, sampleRetention(nil))
+	// Windows of the Watcher, rather than of the environment
+	assert.Equal(t, time.Hour, sampleRetention([]time.Duration{time.Minute, time.Hour, 30 * time.Minute}))
+	assert.Equal(t, 5*time.Minute, sampleRetention([]time.Duration{5 * time.Minute}))
+}
+
+func TestWaitForCacheSync(t *testing.T) {
+	defer func(timeout time.Duration) { kubeCacheSyncTimeout = timeout }(kubeCacheSyncTimeout)
+	kubeCacheSyncTimeout = 50 * time.Millisecond
+	stopCh := make(chan struct{})
+	synced := func() bool { return true }
+	neverSynced := func() bool { return false }
+
+	assert.Nil(t, waitForCacheSync(stopCh, "nodes", synced))
+	// Caches which never sync, e.g. when RBAC forbids listing nodes, fail in bounded time
+	err := waitForCacheSync(stopCh, "nodes", synced, neverSynced)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "nodes")
+	close(stopCh)
+	kubeCacheSyncTimeout = time.Hour
+	assert.NotNil(t, waitForCacheSync(stopCh, "endpointslices", neverSynced))
+}
+
+// Returns the node metrics in list responses, as the fake metrics clientset does not serve them from its tracker
+func nodeMetricsListReactor(nodeMetrics ...*metricsv1beta1.NodeMetrics) func(action k8stesting.Action) (bool, runtime.Object, error) {
+	return func(action k8stesting.Action) (bool, runtime.Object, error) {
+		list := &metricsv1beta1.NodeMetricsList{}
+		for _, m := range nodeMetrics {
+			list.Items = append(list.Items, *m)
+		}
+		return true, list, nil
+	}
+}
+
+func metricsByOperator(metrics []watcher.Metric, metricType string) map[string]float64 {
+	values := make(map[string]float64)
+	for _, metric := range metrics {
+		if metric.Type == metricType {
+			values[metric.Operator] = metric.Value
+		}
+	}
+	return values
+}
@@ -20,12 +20,16 @@ import (
 	"context"
 	"crypto/tls"
 	"crypto/x509"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net"
 	"net/http"
 	"net/url"
 	"os"
+	"strconv"
+	"sync"
 	"time"
 
 	"k8s.io/client-go/transport"
@@ -65,10 +69,30 @@ const (
 	promKeplerHostEnergyStat     = "kepler_node_energy_stat"
 	allHosts                     = "all"
 	hostMetricKey                = "instance"
+	promNamespaceLabel           = "namespace"
+	promPodLabel                 = "pod"
+	defaultPromPodNodeLabel      = "node"
+
+	// env variable that provides the maximum number of Prometheus queries run in parallel per fetch
+	PromQueryConcurrencyKey = "PROMETHEUS_QUERY_CONCURRENCY"
+	// env variable that provides the deadline of all Prometheus queries of a fetch, e.g. 45s
+	PromFetchTimeoutKey = "PROMETHEUS_FETCH_TIMEOUT"
+
+	DefaultPromQueryConcurrency = 8
+	// Leaves room within the one minute interval between fetches of a window
+	DefaultPromFetchTimeout = 45 * time.Second
+	promQueryTimeout        = 10 * time.Second
 )
 
+var _ watcher.PodMetricsProviderClient = promClient{}
+
 type promClient struct {
-	client api.Client
+	client       api.Client
+	address      string
+	queries      PromQueriesConfig
+	hosts        promHostResolver
+	concurrency  int
+	fetchTimeout time.Duration
 }
 
 func loadCAFile(filepath string) (*x509.CertPool, error) {
@@ -90,8 +114,23 @@ func NewPromClient(opts watcher.MetricsProviderOpts) (watcher.MetricsProviderCli
 		return nil, fmt.Errorf("metric provider name should be %v, found %v", watcher.PromClientName, opts.Name)
 	}
 
+	queries, err := loadPromQueriesConfig(os.Getenv(PromQueriesConfigKey))
+	if err != nil {
+		return nil, err
+	}
+	concurrency, fetchTimeout := DefaultPromQueryConcurrency, DefaultPromFetchTimeout
+	if value, ok := os.LookupEnv(PromQueryConcurrencyKey); ok {
+		if concurrency, err = strconv.Atoi(value); err != nil || concurrency < 1 {
+			return nil, fmt.Errorf("invalid %v %q, should be a positive integer", PromQueryConcurrencyKey, value)
+		}
+	}
+	if value, ok := os.LookupEnv(PromFetchTimeoutKey); ok {
+		if fetchTimeout, err = time.ParseDuration(value); err != nil || fetchTimeout <= 0 {
+			return nil, fmt.Errorf("invalid %v %q, should be a positive duration", PromFetchTimeoutKey, value)
+		}
+	}
+
 	var client api.Client
-	var err error
 	var promToken, promAddress = "", DefaultPromAddress
 	if opts.AuthToken != "" {
 		promToken = opts.AuthToken
@@ -156,74 +195,172 @@ func NewPromClient(opts watcher.MetricsProviderOpts) (watcher.MetricsProviderCli
 		log.Errorf("error creating prometheus client: %v", err)
 		return nil, err
 	}
+	// Created last, as the kubernetes strategy watches nodes until the client is closed
+	hosts, err := newPromHostResolver(queries.HostResolution, queries.HostLabel)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create Prometheus host resolver: %v", err)
+	}
 
-	return promClient{client}, err
+	return promClient{
+		client:       client,
+		address:      promAddress,
+		queries:      queries,
+		hosts:        hosts,
+		concurrency:  concurrency,
+		fetchTimeout: fetchTimeout,
+	}, err
 }
 
 func (s promClient) Name() string {
 	return watcher.PromClientName
 }
 
+// Close Stops watching nodes if hosts are resolved through the Kubernetes API
+func (s promClient) Close() error {
+	if closer, ok := s.hosts.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
 func (s promClient) FetchHostMetrics(host string, window *watcher.Window) ([]watcher.Metric, error) {
-	var metricList []watcher.Metric
-	var anyerr error
-
-	for _, method := range []string{promAvg, promStd} {
-		for _, metric := range []string{promCpuMetric, promMemMetric, promTransBandMetric, promTransBandDropMetric, promRecBandMetric, promRecBandDropMetric,
-			promDiskIOMetric, promScaphHostPower, promScaphHostJoules, promKeplerHostCoreJoules, promKeplerHostUncoreJoules, promKeplerHostDRAMJoules,
-			promKeplerHostPackageJoules, promKeplerHostOtherJoules, promKeplerHostGPUJoules, promKeplerHostPlatformJoules, promKeplerHostEnergyStat} {
-			promQuery := s.buildPromQuery(host, metric, method, window.Duration)
-			promResults, err := s.getPromResults(promQuery)
-
-			if err != nil {
-				log.Errorf("error querying Prometheus for query %v: %v\n", promQuery, err)
-				anyerr = err
-				continue
-			}
+	return s.FetchHostMetricsContext(context.Background(), host, window)
+}
 
-			curMetricMap := s.promResults2MetricMap(promResults, metric, method, window.Duration)
-			metricList = append(metricList, curMetricMap[host]...)
-		}
+func (s promClient) FetchHostMetricsContext(ctx context.Context, host string, window *watcher.Window) ([]watcher.Metric, error) {
+	if s.queries.HostResolution.Strategy != PromHostLabel {
+		// Node names can't be mapped back to host label values, so the host is selected from the results of all hosts
+		hostMetrics, err := s.fetchHostsMetrics(ctx, window.Duration, allHosts)
+		return hostMetrics[host], err
 	}
-
-	return metricList, anyerr
+	hostMetrics, err := s.fetchHostsMetrics(ctx, window.Duration, host)
+	return hostMetrics[host], err
 }
 
-// FetchAllHostsMetrics Fetch all host metrics with different operators (avg_over_time, stddev_over_time) and different resource types (CPU, Memory)
+// FetchAllHostsMetrics Fetch all host metrics of every configured query with its operators (avg_over_time, stddev_over_time, etc.)
 func (s promClient) FetchAllHostsMetrics(window *watcher.Window) (map[string][]watcher.Metric, error) {
+	return s.FetchAllHostsMetricsContext(context.Background(), window)
+}
+
+func (s promClient) FetchAllHostsMetricsContext(ctx context.Context, window *watcher.Window) (map[string][]watcher.Metric, error) {
+	return s.fetchHostsMetrics(ctx, window.Duration, allHosts)
+}
+
+// FetchAllPodsMetrics Fetch all pod metrics of every configured pod query with its operators. Returns nil if no pod queries are configured
+func (s promClient) FetchAllPodsMetrics(window *watcher.Window) (watcher.PodMetricsMap, error) {
+	return s.FetchAllPodsMetricsContext(context.Background(), window)
+}
+
+func (s promClient) FetchAllPodsMetricsContext(ctx context.Context, window *watcher.Window) (watcher.PodMetricsMap, error) {
+	if len(s.queries.PodQueries) == 0 {
+		return nil, nil
+	}
+	jobs, err := s.runQueries(ctx, s.queries.PodQueries, window.Duration, allHosts)
+	podMetrics := make(watcher.PodMetricsMap)
+	for _, job := range jobs {
+		if job.result != nil {
+			s.promResults2PodMetrics(podMetrics, job.result, job.query, job.operator, window.Duration)
+		}
+	}
+	return podMetrics, err
+}
+
+// Fetches the metrics of a host, or all hosts, merging results in the order of the configured queries
+func (s promClient) fetchHostsMetrics(ctx context.Context, rollup string, host string) (map[string][]watcher.Metric, error) {
+	jobs, err := s.runQueries(ctx, s.queries.Queries, rollup, host)
 	hostMetrics := make(map[string][]watcher.Metric)
-	var anyerr error
-
-	for _, method := range []string{promAvg, promStd} {
-		for _, metric := range []string{promCpuMetric, promMemMetric, promTransBandMetric, promTransBandDropMetric, promRecBandMetric, promRecBandDropMetric,
-			promDiskIOMetric, promScaphHostPower, promScaphHostJoules, promKeplerHostCoreJoules, promKeplerHostUncoreJoules, promKeplerHostDRAMJoules,
-			promKeplerHostPackageJoules, promKeplerHostOtherJoules, promKeplerHostGPUJoules, promKeplerHostPlatformJoules, promKeplerHostEnergyStat} {
-			promQuery := s.buildPromQuery(allHosts, metric, method, window.Duration)
-			promResults, err := s.getPromResults(promQuery)
-
-			if err != nil {
-				log.Errorf("error querying Prometheus for query %v: %v\n", promQuery, err)
-				anyerr = err
-				continue
-			}
+	for _, job := range jobs {
+		if job.result == nil {
+			continue
+		}
+		for k, v := range s.promResults2MetricMap(job.result, job.query, job.operator, rollup) {
+			hostMetrics[k] = append(hostMetrics[k], v...)
+		}
+	}
+	return hostMetrics, err
+}
 
-			curMetricMap := s.promResults2MetricMap(promResults, metric, method, window.Duration)
+// A configured query to run for an operator, and its result once run successfully
+type promQueryJob struct {
+	query    *PromQuery
+	operator string
+	result   model.Value
+}
+
+// Runs every query with its operators for a window and host, or allHosts, in parallel up to the configured
+// concurrency and within the fetch deadline. Returns a job per query and operator, in the order of the queries.
+// If only some queries fail, their errors are returned as a watcher.PartialMetricsError
+func (s promClient) runQueries(ctx context.Context, queries []PromQuery, rollup string, host string) ([]promQueryJob, error) {
+	var jobs []promQueryJob
+	for i := range queries {
+		for _, operator := range queries[i].Operators {
+			jobs = append(jobs, promQueryJob{query: &queries[i], operator: operator})
+		}
+	}
 
-			for k, v := range curMetricMap {
-				hostMetrics[k] = append(hostMetrics[k], v...)
+	ctx, cancel := context.WithTimeout(ctx, s.fetchTimeout)
+	defer cancel()
+	errs := make([]error, len(jobs))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for worker := 0; worker < s.concurrency && worker < len(jobs); worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				jobs[i].result, errs[i] = s.runQuery(ctx, jobs[i].query, jobs[i].operator, rollup, host)
 			}
+		}()
+	}
+	for i := range jobs {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	var failed []error
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err)
 		}
 	}
+	switch {
+	case len(failed) == 0:
+		return jobs, nil
+	case len(failed) == len(jobs):
+		return jobs, errors.Join(failed...)
+	default:
+		return jobs, &watcher.PartialMetricsError{
+			Err: fmt.Errorf("%d of %d Prometheus queries failed: %w", len(failed), len(jobs), errors.Join(failed...)),
+		}
+	}
+}
 
-	return hostMetrics, anyerr
+// Runs a configured query for an operator and window, for a host or allHosts
+func (s promClient) runQuery(ctx context.Context, query *PromQuery, operator string, rollup string, host string) (model.Value, error) {
+	promQuery, err := s.queries.render(query, operator, rollup, host)
+	if err != nil {
+		log.Errorf("error rendering Prometheus query for metric %v: %v\n", query.Metric, err)
+		return nil, err
+	}
+	promResults, err := s.getPromResults(ctx, promQuery)
+	if err != nil {
+		log.Errorf("error querying Prometheus for query %v: %v\n", promQuery, err)
+		return nil, fmt.Errorf("query %v: %w", promQuery, err)
+	}
+	return promResults, nil
 }
 
 func (s promClient) Health() (int, error) {
-	req, err := http.NewRequest("HEAD", DefaultPromAddress, nil)
+	return s.HealthContext(context.Background())
+}
+
+func (s promClient) HealthContext(ctx context.Context) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, "HEAD", s.address, nil)
 	if err != nil {
 		return -1, err
 	}
-	resp, _, err := s.client.Do(context.Background(), req)
+	resp, _, err := s.client.Do(ctx, req)
 	if err != nil {
 		return -1, err
 	}
@@ -233,24 +370,16 @@ func (s promClient) Health() (int, error) {
 	return 0, nil
 }
 
-func (s promClient) buildPromQuery(host string, metric string, method string, rollup string) string {
-	var promQuery string
-
-	if host == allHosts {
-		promQuery = fmt.Sprintf("%s(%s[%s])", method, metric, rollup)
-	} else {
-		promQuery = fmt.Sprintf("%s(%s{%s=\"%s\"}[%s])", method, metric, hostMetricKey, host, rollup)
-	}
-
-	return promQuery
-}
-
-func (s promClient) getPromResults(promQuery string) (model.Value, error) {
+func (s promClient) getPromResults(ctx context.Context, promQuery string) (model.Value, error) {
 	v1api := v1.NewAPI(s.client)
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, promQueryTimeout)
 	defer cancel()
 
 	results, warnings, err := v1api.Query(ctx, promQuery, time.Now())
+	var apiErr *v1.Error
+	if errors.As(err, &apiErr) && (apiErr.Type == v1.ErrServer || apiErr.Type == v1.ErrTimeout) {
+		return nil, &watcher.RetryableError{Err: err}
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -262,45 +391,14 @@ func (s promClient) getPromResults(promQuery string) (model.Value, error) {
 	return results, nil
 }
 
-func (s promClient) promResults2MetricMap(promresults model.Value, metric string, method string, rollup string) map[string][]watcher.Metric {
-	var metricType string
-	var operator string
-
+func (s promClient) promResults2MetricMap(promresults model.Value, query *PromQuery, operator string, rollup string) map[string][]watcher.Metric {
 	curMetrics := make(map[string][]watcher.Metric)
 
-	switch metric {
-	case promCpuMetric: // CPU metrics
-		metricType = watcher.CPU
-	case promMemMetric: // Memory metrics
-		metricType = watcher.Memory
-	case promDiskIOMetric: // Storage metrics
-		metricType = watcher.Storage
-	case promScaphHostPower, promScaphHostJoules, // Energy-related metrics
-		promKeplerHostCoreJoules, promKeplerHostUncoreJoules,
-		promKeplerHostDRAMJoules, promKeplerHostPackageJoules,
-		promKeplerHostOtherJoules, promKeplerHostGPUJoules,
-		promKeplerHostPlatformJoules, promKeplerHostEnergyStat:
-		metricType = watcher.Energy
-	case promTransBandMetric, promTransBandDropMetric, // Bandwidth-related metrics
-		promRecBandMetric, promRecBandDropMetric:
-		metricType = watcher.Bandwidth
-	default:
-		metricType = watcher.Unknown
-	}
-
-	if method == promAvg {
-		operator = watcher.Average
-	} else if method == promStd {
-		operator = watcher.Std
-	} else {
-		operator = watcher.UnknownOperator
-	}
-
 	switch promresults.(type) {
 	case model.Vector:
 		for _, result := range promresults.(model.Vector) {
-			curMetric := watcher.Metric{Name: metric, Type: metricType, Operator: operator, Rollup: rollup, Value: float64(result.Value * 100)}
-			curHost := string(result.Metric[hostMetricKey])
+			curMetric := watcher.Metric{Name: query.Metric, Type: query.Type, Operator: operator, Rollup: rollup, Value: float64(result.Value) * query.Scale}
+			curHost := s.hosts.resolve(result.Metric)
 			curMetrics[curHost] = append(curMetrics[curHost], curMetric)
 		}
 	default:
@@ -309,3 +407,28 @@ func (s promClient) promResults2MetricMap(promresults model.Value, metric string
 
 	return curMetrics
 }
+
+// Adds pod query results to podMetrics. Results without namespace and pod labels are ignored
+func (s promClient) promResults2PodMetrics(podMetrics watcher.PodMetricsMap, promresults model.Value, query *PromQuery, operator string, rollup string) {
+	vector, ok := promresults.(model.Vector)
+	if !ok {
+		log.Errorf("error: The Prometheus results should not be type: %v.\n", promresults.Type())
+		return
+	}
+	for _, result := range vector {
+		namespace, name := string(result.Metric[promNamespaceLabel]), string(result.Metric[promPodLabel])
+		if namespace == "" || name == "" {
+			continue
+		}
+		key := watcher.PodKey(namespace, name)
+		pod, ok := podMetrics[key]
+		if !ok {
+			pod = watcher.PodMetrics{Namespace: namespace, Name: name}
+		}
+		if node := string(result.Metric[model.LabelName(s.queries.PodNodeLabel)]); node != "" {
+			pod.Node = node
+		}
+		pod.Metrics = append(pod.Metrics, watcher.Metric{Name: query.Metric, Type: query.Type, Operator: operator, Rollup: rollup, Value: float64(result.Value) * query.Scale})
+		podMetrics[key] = pod
+	}
+}
@@ -0,0 +1,226 @@
+/*
+Copyright 2020
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metricsprovider
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+
+	"github.com/prometheus/common/model"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// Host resolution strategies, mapping Prometheus results to node names
+	// Uses the value of the host label as is
+	PromHostLabel = "label"
+	// Strips the port from the host label, e.g. 10.0.1.5:9100 to 10.0.1.5
+	PromHostStripPort = "stripPort"
+	// Replaces the host label matching a regular expression, e.g. (.+)\.ec2\.internal:\d+ with $1
+	PromHostRegex = "regex"
+	// Joins query results with an info metric, using its node label, e.g. nodename of node_uname_info
+	PromHostJoin = "join"
+	// Looks up the node having the InternalIP of the host label among the nodes watched through the Kubernetes API
+	PromHostKubernetes = "kubernetes"
+
+	defaultPromJoinMetric = "node_uname_info"
+	defaultPromNodeLabel  = "nodename"
+
+	// Name of the index of watched nodes by InternalIP
+	nodeInternalIPIndex = "internalIP"
+)
+
+// PromHostResolution Configures how Prometheus results are mapped to node names
+type PromHostResolution struct {
+	// One of label, stripPort, regex, join or kubernetes. label if empty
+	Strategy string `json:"strategy,omitempty"`
+	// Regular expression matching the whole host label, for the regex strategy
+	Regex string `json:"regex,omitempty"`
+	// Replacement of the host label matching Regex, which may refer to capture groups such as $1
+	Replacement string `json:"replacement,omitempty"`
+	// Info metric joined on the host label, node_uname_info if empty, for the join strategy
+	JoinMetric string `json:"joinMetric,omitempty"`
+	// Label of JoinMetric holding the node name, nodename if empty, for the join strategy
+	NodeLabel string `json:"nodeLabel,omitempty"`
+
+	regex *regexp.Regexp
+}
+
+// Validates the host resolution config, setting defaults
+func (r *PromHostResolution) compile() error {
+	switch r.Strategy {
+	case "":
+		r.Strategy = PromHostLabel
+	case PromHostLabel, PromHostStripPort, PromHostKubernetes:
+	case PromHostRegex:
+		if r.Regex == "" {
+			return fmt.Errorf("host resolution strategy %v requires a regex", r.Strategy)
+		}
+		var err error
+		if r.regex, err = regexp.Compile("^(?:" + r.Regex + ")$"); err != nil {
+			return fmt.Errorf("invalid host resolution regex %q: %v", r.Regex, err)
+		}
+	case PromHostJoin:
+		if r.JoinMetric == "" {
+			r.JoinMetric = defaultPromJoinMetric
+		}
+		if r.NodeLabel == "" {
+			r.NodeLabel = defaultPromNodeLabel
+		}
+	default:
+		return fmt.Errorf("unsupported host resolution strategy %v", r.Strategy)
+	}
+	return nil
+}
+
+// Maps the labels of a Prometheus result to the name of its node
+type promHostResolver interface {
+	resolve(labels model.Metric) string
+}
+
+// Builds the resolver of a host resolution config. hostLabel is the label identifying the host in query results
+func newPromHostResolver(r PromHostResolution, hostLabel string) (promHostResolver, error) {
+	label := model.LabelName(hostLabel)
+	switch r.Strategy {
+	case PromHostStripPort:
+		return stripPortResolver{label: label}, nil
+	case PromHostRegex:
+		return regexResolver{label: label, regex: r.regex, replacement: r.Replacement}, nil
+	case PromHostJoin:
+		return labelResolver{label: model.LabelName(r.NodeLabel)}, nil
+	case PromHostKubernetes:
+		informerFactory, stopCh, release, err := acquireKubeInformers()
+		if err != nil {
+			return nil, err
+		}
+		nodeInformer := informerFactory.Core().V1().Nodes().Informer()
+		resolver, err := newKubernetesResolver(nodeInformer, label)
+		if err != nil {
+			release()
+			return nil, err
+		}
+		informerFactory.Start(stopCh)
+		if err = waitForCacheSync(stopCh, "nodes", nodeInformer.HasSynced); err != nil {
+			release()
+			return nil, err
+		}
+		resolver.release = release
+		return resolver, nil
+	default:
+		return labelResolver{label: label}, nil
+	}
+}
+
+type labelResolver struct {
+	label model.LabelName
+}
+
+func (r labelResolver) resolve(labels model.Metric) string {
+	return string(labels[r.label])
+}
+
+type stripPortResolver struct {
+	label model.LabelName
+}
+
+func (r stripPortResolver) resolve(labels model.Metric) string {
+	return stripPort(string(labels[r.label]))
+}
+
+// Returns the host of a host:port address, or address if it has no port
+func stripPort(address string) string {
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		return host
+	}
+	return address
+}
+
+type regexResolver struct {
+	label       model.LabelName
+	regex       *regexp.Regexp
+	replacement string
+}
+
+// Hosts not matching the regex are kept as is
+func (r regexResolver) resolve(labels model.Metric) string {
+	host := string(labels[r.label])
+	match := r.regex.FindStringSubmatchIndex(host)
+	if match == nil {
+		return host
+	}
+	return string(r.regex.ExpandString(nil, r.replacement, host, match))
+}
+
+// Resolves hosts by the InternalIP of nodes, looked up in the node informer shared with the other Kubernetes clients
+type kubernetesResolver struct {
+	nodes cache.Indexer
+	label model.LabelName
+	// Releases the node informer, nil if the resolver does not own a reference to it
+	release func()
+}
+
+// Indexes the nodes of nodeInformer by InternalIP, unless another resolver did already
+func newKubernetesResolver(nodeInformer cache.SharedIndexInformer, label model.LabelName) (*kubernetesResolver, error) {
+	if _, ok := nodeInformer.GetIndexer().GetIndexers()[nodeInternalIPIndex]; !ok {
+		if err := nodeInformer.AddIndexers(cache.Indexers{nodeInternalIPIndex: nodeInternalIPs}); err != nil {
+			return nil, fmt.Errorf("unable to index nodes by InternalIP: %v", err)
+		}
+	}
+	return &kubernetesResolver{nodes: nodeInformer.GetIndexer(), label: label}, nil
+}
+
+// Close Stops watching nodes, unless the informer is used by other clients
+func (r *kubernetesResolver) Close() error {
+	if r.release != nil {
+		r.release()
+	}
+	return nil
+}
+
+// Hosts without a matching node are kept as is
+func (r *kubernetesResolver) resolve(labels model.Metric) string {
+	host := string(labels[r.label])
+	nodes, err := r.nodes.ByIndex(nodeInternalIPIndex, stripPort(host))
+	if err != nil {
+		log.Errorf("unable to look up the node of Prometheus host %v: %v", host, err)
+		return host
+	}
+	for _, obj := range nodes {
+		if node, ok := obj.(*v1.Node); ok {
+			return node.Name
+		}
+	}
+	return host
+}
+
+// Returns the InternalIP addresses of a node
+func nodeInternalIPs(obj interface{}) ([]string, error) {
+	node, ok := obj.(*v1.Node)
+	if !ok {
+		return nil, nil
+	}
+	var addresses []string
+	for _, address := range node.Status.Addresses {
+		if address.Type == v1.NodeInternalIP {
+			addresses = append(addresses, address.Address)
+		}
+	}
+	return addresses, nil
+}
@@ -0,0 +1,214 @@
+/*
+Copyright 2020
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metricsprovider
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/paypal/load-watcher/pkg/watcher"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// env variable that provides path to a YAML or JSON file configuring the Prometheus queries
+	PromQueriesConfigKey = "PROMETHEUS_QUERIES_CONFIG"
+	// Query template used when a query has none, e.g. avg_over_time(instance:node_cpu:ratio[15m])
+	defaultPromQueryTemplate = "{{.Function}}({{.Metric}}{{.Selector}}[{{.Window}}])"
+)
+
+// Prometheus functions over time used for watcher operators
+var promOperatorFunctions = map[string]string{
+	watcher.Average: promAvg,
+	watcher.Std:     promStd,
+	watcher.Max:     "max_over_time",
+	watcher.Min:     "min_over_time",
+}
+
+// PromQueriesConfig Configures the queries run by the Prometheus client
+type PromQueriesConfig struct {
+	// Label identifying the host in query results
+	HostLabel string `json:"hostLabel,omitempty"`
+	// Mapping of hosts to node names, the host label is used as is by default
+	HostResolution PromHostResolution `json:"hostResolution,omitempty"`
+	// Queries to run for every window, the default profile if omitted
+	Queries []PromQuery `json:"queries"`
+	// Queries of pod metrics to run for every window, whose results are identified by namespace and pod labels.
+	// Pod metrics are not fetched if empty
+	PodQueries []PromQuery `json:"podQueries,omitempty"`
+	// Label of pod query results identifying the node of the pod, node if empty
+	PodNodeLabel string `json:"podNodeLabel,omitempty"`
+}
+
+// PromQuery Configures a query, which is run once per operator
+type PromQuery struct {
+	// Metric name, reported as the watcher metric name
+	Metric string `json:"metric"`
+	// PromQL Go template, defaultPromQueryTemplate if empty. Available fields are:
+	//   .Metric     metric name
+	//   .Function   Prometheus function over time for the operator, e.g. avg_over_time
+	//   .Window     window duration, e.g. 15m
+	//   .Selector   label selector of the host, e.g. {instance="node-1"}, empty when querying all hosts
+	//   .HostMatcher label matcher of the host, e.g. instance="node-1", empty when querying all hosts
+	Query string `json:"query,omitempty"`
+	// Watcher metric type such as CPU or Memory, Unknown if empty
+	Type string `json:"type,omitempty"`
+	// Watcher operators such as AVG or STD
+	Operators []string `json:"operators"`
+	// Factor the query results are multiplied by, e.g. 100 for ratios. 1 if not set
+	Scale float64 `json:"scale,omitempty"`
+
+	template *template.Template
+	pod      bool // A query of pod metrics
+}
+
+// Fields available to query templates
+type promQueryParams struct {
+	Metric      string
+	Function    string
+	Window      string
+	Selector    string
+	HostMatcher string
+}
+
+// The default profile, relying on node-exporter recording rules, Scaphandre and Kepler
+func defaultPromQueriesConfig() PromQueriesConfig {
+	query := func(metric string, metricType string) PromQuery {
+		return PromQuery{Metric: metric, Type: metricType, Operators: []string{watcher.Average, watcher.Std}, Scale: 100}
+	}
+	return PromQueriesConfig{
+		HostLabel: hostMetricKey,
+		Queries: []PromQuery{
+			query(promCpuMetric, watcher.CPU),
+			query(promMemMetric, watcher.Memory),
+			query(promTransBandMetric, watcher.Bandwidth),
+			query(promTransBandDropMetric, watcher.Bandwidth),
+			query(promRecBandMetric, watcher.Bandwidth),
+			query(promRecBandDropMetric, watcher.Bandwidth),
+			query(promDiskIOMetric, watcher.Storage),
+			query(promScaphHostPower, watcher.Energy),
+			query(promScaphHostJoules, watcher.Energy),
+			query(promKeplerHostCoreJoules, watcher.Energy),
+			query(promKeplerHostUncoreJoules, watcher.Energy),
+			query(promKeplerHostDRAMJoules, watcher.Energy),
+			query(promKeplerHostPackageJoules, watcher.Energy),
+			query(promKeplerHostOtherJoules, watcher.Energy),
+			query(promKeplerHostGPUJoules, watcher.Energy),
+			query(promKeplerHostPlatformJoules, watcher.Energy),
+			query(promKeplerHostEnergyStat, watcher.Energy),
+		},
+	}
+}
+
+// Loads the queries config from the file at path, or the default profile if path is empty
+func loadPromQueriesConfig(path string) (PromQueriesConfig, error) {
+	if path == "" {
+		config := defaultPromQueriesConfig()
+		return config, config.compile()
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return PromQueriesConfig{}, fmt.Errorf("unable to read Prometheus queries config: %v", err)
+	}
+	var config PromQueriesConfig
+	if err = yaml.UnmarshalStrict(data, &config); err != nil {
+		return PromQueriesConfig{}, fmt.Errorf("unable to parse Prometheus queries config %v: %v", path, err)
+	}
+	if config.Queries == nil {
+		config.Queries = defaultPromQueriesConfig().Queries
+	}
+	return config, config.compile()
+}
+
+// Validates the config, setting defaults and parsing query templates
+func (c *PromQueriesConfig) compile() error {
+	if c.HostLabel == "" {
+		c.HostLabel = hostMetricKey
+	}
+	if err := c.HostResolution.compile(); err != nil {
+		return err
+	}
+	if len(c.Queries) == 0 {
+		return fmt.Errorf("no Prometheus queries configured")
+	}
+	if c.PodNodeLabel == "" {
+		c.PodNodeLabel = defaultPromPodNodeLabel
+	}
+	if err := compilePromQueries(c.Queries, false); err != nil {
+		return err
+	}
+	return compilePromQueries(c.PodQueries, true)
+}
+
+// Validates queries, setting defaults and parsing query templates
+func compilePromQueries(queries []PromQuery, pod bool) error {
+	for i := range queries {
+		q := &queries[i]
+		q.pod = pod
+		if q.Metric == "" {
+			return fmt.Errorf("query %d has no metric", i)
+		}
+		if q.Type == "" {
+			q.Type = watcher.Unknown
+		}
+		if q.Scale == 0 {
+			q.Scale = 1
+		}
+		if len(q.Operators) == 0 {
+			return fmt.Errorf("query %v has no operators", q.Metric)
+		}
+		for _, operator := range q.Operators {
+			if _, ok := promOperatorFunctions[operator]; !ok {
+				return fmt.Errorf("query %v has unsupported operator %v", q.Metric, operator)
+			}
+		}
+		queryTemplate := q.Query
+		if queryTemplate == "" {
+			queryTemplate = defaultPromQueryTemplate
+		}
+		var err error
+		if q.template, err = template.New(q.Metric).Option("missingkey=error").Parse(queryTemplate); err != nil {
+			return fmt.Errorf("invalid query template of %v: %v", q.Metric, err)
+		}
+	}
+	return nil
+}
+
+// Renders the PromQL of the query for an operator, window and host, or all hosts if host is allHosts
+func (c *PromQueriesConfig) render(q *PromQuery, operator string, window string, host string) (string, error) {
+	params := promQueryParams{
+		Metric:   q.Metric,
+		Function: promOperatorFunctions[operator],
+		Window:   window,
+	}
+	if host != allHosts {
+		params.HostMatcher = fmt.Sprintf("%s=%q", c.HostLabel, host)
+		params.Selector = "{" + params.HostMatcher + "}"
+	}
+	builder := strings.Builder{}
+	if err := q.template.Execute(&builder, params); err != nil {
+		return "", err
+	}
+	if c.HostResolution.Strategy == PromHostJoin && !q.pod {
+		// The info metric has value 1, so the join adds its node label without changing values
+		return fmt.Sprintf("(%s) * on(%s) group_left(%s) %s", builder.String(), c.HostLabel,
+			c.HostResolution.NodeLabel, c.HostResolution.JoinMetric), nil
+	}
+	return builder.String(), nil
+}
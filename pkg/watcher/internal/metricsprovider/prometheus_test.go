@@ -0,0 +1,404 @@
+package metricsprovider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/paypal/load-watcher/pkg/watcher"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// Serves instant queries, answering each query with the vector returned by results
+func newFakePromServer(t *testing.T, results func(query string) string) (*httptest.Server, *[]string) {
+	var mutex sync.Mutex
+	var queries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Nil(t, r.ParseForm())
+		query := r.Form.Get("query")
+		mutex.Lock()
+		queries = append(queries, query)
+		mutex.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"status":"success","data":{"resultType":"vector","result":[%s]}}`, results(query))
+	}))
+	return server, &queries
+}
+
+func TestPromHealthUsesConfiguredAddress(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client, err := NewPromClient(watcher.MetricsProviderOpts{Name: watcher.PromClientName, Address: server.URL})
+	assert.Nil(t, err)
+	status, err := client.Health()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, status)
+
+	server.Close()
+	status, _ = client.Health()
+	assert.Equal(t, -1, status)
+}
+
+func TestPromDefaultQueries(t *testing.T) {
+	config, err := loadPromQueriesConfig("")
+	require.Nil(t, err)
+	assert.Equal(t, hostMetricKey, config.HostLabel)
+	assert.Len(t, config.Queries, 17)
+
+	query, err := config.render(&config.Queries[0], watcher.Average, watcher.FifteenMinutes, allHosts)
+	require.Nil(t, err)
+	assert.Equal(t, "avg_over_time(instance:node_cpu:ratio[15m])", query)
+	query, err = config.render(&config.Queries[1], watcher.Std, watcher.FiveMinutes, "10.0.1.5:9100")
+	require.Nil(t, err)
+	assert.Equal(t, `stddev_over_time(instance:node_memory_utilisation:ratio{instance="10.0.1.5:9100"}[5m])`, query)
+}
+
+func TestPromQueriesConfigFile(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "queries.yaml")
+	require.Nil(t, os.WriteFile(configFile, []byte(`
+hostLabel: node
+queries:
+- metric: node_cpu_utilisation
+  query: '{{.Function}}(rate(node_cpu_seconds_total{mode!="idle",{{.HostMatcher}}}[1m])[{{.Window}}:])'
+  type: CPU
+  operators: [AVG, MAX]
+  scale: 100
+- metric: node_memory_utilisation
+  type: Memory
+  operators: [AVG]
+`), 0600))
+
+	var queries *[]string
+	var server *httptest.Server
+	server, queries = newFakePromServer(t, func(query string) string {
+		if strings.Contains(query, "node_cpu_seconds_total") {
+			return `{"metric":{"node":"worker-1"},"value":[1700000000,"0.25"]}`
+		}
+		return `{"metric":{"node":"worker-1"},"value":[1700000000,"40"]},{"metric":{"node":"worker-2"},"value":[1700000000,"60"]}`
+	})
+	defer server.Close()
+
+	t.Setenv(PromQueriesConfigKey, configFile)
+	client, err := NewPromClient(watcher.MetricsProviderOpts{Name: watcher.PromClientName, Address: server.URL})
+	require.Nil(t, err)
+
+	metrics, err := client.FetchAllHostsMetrics(watcher.CurrentFifteenMinuteWindow())
+	require.Nil(t, err)
+	assert.ElementsMatch(t, []string{
+		`avg_over_time(rate(node_cpu_seconds_total{mode!="idle",}[1m])[15m:])`,
+		`max_over_time(rate(node_cpu_seconds_total{mode!="idle",}[1m])[15m:])`,
+		`avg_over_time(node_memory_utilisation[15m])`,
+	}, *queries)
+	assert.ElementsMatch(t, []watcher.Metric{
+		{Name: "node_cpu_utilisation", Type: watcher.CPU, Operator: watcher.Average, Rollup: watcher.FifteenMinutes, Value: 25},
+		{Name: "node_cpu_utilisation", Type: watcher.CPU, Operator: watcher.Max, Rollup: watcher.FifteenMinutes, Value: 25},
+		{Name: "node_memory_utilisation", Type: watcher.Memory, Operator: watcher.Average, Rollup: watcher.FifteenMinutes, Value: 40},
+	}, metrics["worker-1"])
+	assert.Equal(t, []watcher.Metric{
+		{Name: "node_memory_utilisation", Type: watcher.Memory, Operator: watcher.Average, Rollup: watcher.FifteenMinutes, Value: 60},
+	}, metrics["worker-2"])
+
+	*queries = nil
+	_, err = client.FetchHostMetrics("worker-1", watcher.CurrentFiveMinuteWindow())
+	require.Nil(t, err)
+	assert.Contains(t, *queries, `avg_over_time(node_memory_utilisation{node="worker-1"}[5m])`)
+}
+
+func TestPromQueriesConfigInvalid(t *testing.T) {
+	for _, config := range []string{
+		`queries: []`,
+		`queries: [{metric: cpu, operators: [MEDIAN]}]`,
+		`queries: [{metric: cpu, operators: [AVG], query: "{{.Function"}]`,
+		`queries: [{metric: cpu, operators: [AVG], unknownField: true}]`,
+	} {
+		configFile := filepath.Join(t.TempDir(), "queries.yaml")
+		require.Nil(t, os.WriteFile(configFile, []byte(config), 0600))
+		_, err := loadPromQueriesConfig(configFile)
+		assert.NotNil(t, err, config)
+	}
+}
+
+func TestPromFetchConcurrency(t *testing.T) {
+	var running, maxRunning int32
+	server, queries := newFakePromServer(t, func(query string) string {
+		cur := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			prev := atomic.LoadInt32(&maxRunning)
+			if cur <= prev || atomic.CompareAndSwapInt32(&maxRunning, prev, cur) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		return `{"metric":{"instance":"node-1"},"value":[1700000000,"0.5"]}`
+	})
+	defer server.Close()
+
+	t.Setenv(PromQueryConcurrencyKey, "4")
+	client, err := NewPromClient(watcher.MetricsProviderOpts{Name: watcher.PromClientName, Address: server.URL})
+	require.Nil(t, err)
+
+	metrics, err := client.FetchAllHostsMetrics(watcher.CurrentFifteenMinuteWindow())
+	require.Nil(t, err)
+	assert.Len(t, *queries, 34)
+	assert.Len(t, metrics["node-1"], 34)
+	assert.LessOrEqual(t, atomic.LoadInt32(&maxRunning), int32(4))
+	assert.Greater(t, atomic.LoadInt32(&maxRunning), int32(1))
+
+	// Results are merged in the order of the configured queries
+	assert.Equal(t, promCpuMetric, metrics["node-1"][0].Name)
+	assert.Equal(t, watcher.Average, metrics["node-1"][0].Operator)
+	assert.Equal(t, promCpuMetric, metrics["node-1"][1].Name)
+	assert.Equal(t, watcher.Std, metrics["node-1"][1].Operator)
+}
+
+func TestPromFetchDeadlinePartialResults(t *testing.T) {
+	server, _ := newFakePromServer(t, func(query string) string {
+		if !strings.Contains(query, promCpuMetric) {
+			time.Sleep(time.Second)
+		}
+		return `{"metric":{"instance":"node-1"},"value":[1700000000,"0.5"]}`
+	})
+	defer server.Close()
+
+	t.Setenv(PromFetchTimeoutKey, "200ms")
+	client, err := NewPromClient(watcher.MetricsProviderOpts{Name: watcher.PromClientName, Address: server.URL})
+	require.Nil(t, err)
+
+	start := time.Now()
+	metrics, err := client.FetchAllHostsMetrics(watcher.CurrentFifteenMinuteWindow())
+	assert.Less(t, time.Since(start), time.Second)
+	var partialErr *watcher.PartialMetricsError
+	require.True(t, errors.As(err, &partialErr), "%v", err)
+	assert.Equal(t, []watcher.Metric{
+		{Name: promCpuMetric, Type: watcher.CPU, Operator: watcher.Average, Rollup: watcher.FifteenMinutes, Value: 50},
+		{Name: promCpuMetric, Type: watcher.CPU, Operator: watcher.Std, Rollup: watcher.FifteenMinutes, Value: 50},
+	}, metrics["node-1"])
+}
+
+func TestPromFetchAllQueriesFailed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client, err := NewPromClient(watcher.MetricsProviderOpts{Name: watcher.PromClientName, Address: server.URL})
+	require.Nil(t, err)
+	_, err = client.FetchAllHostsMetrics(watcher.CurrentFifteenMinuteWindow())
+	require.NotNil(t, err)
+	var partialErr *watcher.PartialMetricsError
+	assert.False(t, errors.As(err, &partialErr))
+}
+
+func TestPromFetchCanceled(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client, err := NewPromClient(watcher.MetricsProviderOpts{Name: watcher.PromClientName, Address: server.URL})
+	require.Nil(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = client.(watcher.ContextMetricsProviderClient).FetchAllHostsMetricsContext(ctx, watcher.CurrentFifteenMinuteWindow())
+	assert.NotNil(t, err)
+	assert.Less(t, time.Since(start), time.Second)
+
+	_, err = client.(watcher.ContextMetricsProviderClient).HealthContext(ctx)
+	assert.NotNil(t, err)
+}
+
+func TestPromFetchOptionsInvalid(t *testing.T) {
+	for key, value := range map[string]string{
+		PromQueryConcurrencyKey: "0",
+		PromFetchTimeoutKey:     "soon",
+	} {
+		t.Run(key, func(t *testing.T) {
+			t.Setenv(key, value)
+			_, err := NewPromClient(watcher.MetricsProviderOpts{Name: watcher.PromClientName})
+			assert.NotNil(t, err)
+		})
+	}
+}
+
+func TestPromHostResolution(t *testing.T) {
+	for _, test := range []struct {
+		name          string
+		config        string
+		expectedQuery string
+		expectedHosts []string
+	}{
+		{
+			name:          "label",
+			config:        `{}`,
+			expectedQuery: `avg_over_time(instance:node_cpu:ratio[15m])`,
+			expectedHosts: []string{"10.0.1.5:9100", "ip-10-0-1-6.ec2.internal:9100"},
+		},
+		{
+			name:          "stripPort",
+			config:        `{hostResolution: {strategy: stripPort}}`,
+			expectedQuery: `avg_over_time(instance:node_cpu:ratio[15m])`,
+			expectedHosts: []string{"10.0.1.5", "ip-10-0-1-6.ec2.internal"},
+		},
+		{
+			name:          "regex",
+			config:        `{hostResolution: {strategy: regex, regex: '(.+)\.ec2\.internal:\d+', replacement: '$1'}}`,
+			expectedQuery: `avg_over_time(instance:node_cpu:ratio[15m])`,
+			expectedHosts: []string{"10.0.1.5:9100", "ip-10-0-1-6"},
+		},
+		{
+			name:          "join",
+			config:        `{hostResolution: {strategy: join}}`,
+			expectedQuery: `(avg_over_time(instance:node_cpu:ratio[15m])) * on(instance) group_left(nodename) node_uname_info`,
+			expectedHosts: []string{"worker-1", "worker-2"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			configFile := filepath.Join(t.TempDir(), "queries.yaml")
+			require.Nil(t, os.WriteFile(configFile, []byte(test.config), 0600))
+			server, queries := newFakePromServer(t, func(query string) string {
+				return `{"metric":{"instance":"10.0.1.5:9100","nodename":"worker-1"},"value":[1700000000,"0.25"]},` +
+					`{"metric":{"instance":"ip-10-0-1-6.ec2.internal:9100","nodename":"worker-2"},"value":[1700000000,"0.5"]}`
+			})
+			defer server.Close()
+
+			t.Setenv(PromQueriesConfigKey, configFile)
+			client, err := NewPromClient(watcher.MetricsProviderOpts{Name: watcher.PromClientName, Address: server.URL})
+			require.Nil(t, err)
+			metrics, err := client.FetchAllHostsMetrics(watcher.CurrentFifteenMinuteWindow())
+			require.Nil(t, err)
+			assert.Contains(t, *queries, test.expectedQuery)
+			var hosts []string
+			for host := range metrics {
+				hosts = append(hosts, host)
+			}
+			assert.ElementsMatch(t, test.expectedHosts, hosts)
+
+			hostMetrics, err := client.FetchHostMetrics(test.expectedHosts[1], watcher.CurrentFifteenMinuteWindow())
+			require.Nil(t, err)
+			assert.Len(t, hostMetrics, 34)
+			assert.Equal(t, float64(50), hostMetrics[0].Value)
+		})
+	}
+}
+
+func TestPromHostResolutionInvalid(t *testing.T) {
+	for _, config := range []string{
+		`hostResolution: {strategy: dns}`,
+		`hostResolution: {strategy: regex}`,
+		`hostResolution: {strategy: regex, regex: '(.+'}`,
+	} {
+		configFile := filepath.Join(t.TempDir(), "queries.yaml")
+		require.Nil(t, os.WriteFile(configFile, []byte(config), 0600))
+		_, err := loadPromQueriesConfig(configFile)
+		assert.NotNil(t, err, config)
+	}
+}
+
+func TestKubernetesHostResolver(t *testing.T) {
+	node := func(name string, internalIP string) *v1.Node {
+		return &v1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Status: v1.NodeStatus{Addresses: []v1.NodeAddress{
+				{Type: v1.NodeHostName, Address: name},
+				{Type: v1.NodeInternalIP, Address: internalIP},
+			}},
+		}
+	}
+	clientSet := fake.NewSimpleClientset(node("worker-1", "10.0.1.5"))
+	kubeInformersMutex.Lock()
+	kubeInformers = informers.NewSharedInformerFactory(clientSet, 0)
+	kubeInformersStopCh = make(chan struct{})
+	kubeInformersMutex.Unlock()
+
+	resolver, err := newPromHostResolver(PromHostResolution{Strategy: PromHostKubernetes}, string(hostMetricKey))
+	require.Nil(t, err)
+	assert.Equal(t, "worker-1", resolver.resolve(model.Metric{hostMetricKey: "10.0.1.5:9100"}))
+	assert.Equal(t, "10.0.1.6:9100", resolver.resolve(model.Metric{hostMetricKey: "10.0.1.6:9100"}))
+
+	// Nodes are watched rather than listed again
+	_, err = clientSet.CoreV1().Nodes().Create(context.Background(), node("worker-2", "10.0.1.6"), metav1.CreateOptions{})
+	require.Nil(t, err)
+	assert.Eventually(t, func() bool {
+		return resolver.resolve(model.Metric{hostMetricKey: "10.0.1.6:9100"}) == "worker-2"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Resolvers share the node informer and its index
+	sharedResolver, err := newPromHostResolver(PromHostResolution{Strategy: PromHostKubernetes}, string(hostMetricKey))
+	require.Nil(t, err)
+	assert.Equal(t, "worker-2", sharedResolver.resolve(model.Metric{hostMetricKey: "10.0.1.6"}))
+
+	// The node informer is stopped once the clients are closed
+	client := promClient{hosts: resolver}
+	require.Nil(t, client.Close())
+	assert.NotNil(t, kubeInformers)
+	require.Nil(t, promClient{hosts: sharedResolver}.Close())
+	assert.Nil(t, kubeInformers)
+	assert.Nil(t, promClient{hosts: labelResolver{}}.Close())
+}
+
+func TestPromPodQueries(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "queries.yaml")
+	require.Nil(t, os.WriteFile(configFile, []byte(`
+hostResolution: {strategy: join}
+podQueries:
+- metric: container_cpu_usage_seconds_total
+  query: '{{.Function}}(sum by (namespace, pod, node) (rate(container_cpu_usage_seconds_total{container!=""}[1m]))[{{.Window}}:])'
+  type: CPU
+  operators: [AVG, MAX]
+`), 0600))
+	server, queries := newFakePromServer(t, func(query string) string {
+		if !strings.Contains(query, "container_cpu_usage_seconds_total") {
+			return ``
+		}
+		return `{"metric":{"namespace":"default","pod":"web-1","node":"worker-1"},"value":[1700000000,"0.5"]},` +
+			`{"metric":{"namespace":"default"},"value":[1700000000,"1"]}`
+	})
+	defer server.Close()
+
+	t.Setenv(PromQueriesConfigKey, configFile)
+	client, err := NewPromClient(watcher.MetricsProviderOpts{Name: watcher.PromClientName, Address: server.URL})
+	require.Nil(t, err)
+	pods, err := client.(watcher.PodMetricsProviderClient).FetchAllPodsMetrics(watcher.CurrentFifteenMinuteWindow())
+	require.Nil(t, err)
+	// Pod queries are not joined for host resolution
+	assert.ElementsMatch(t, []string{
+		`avg_over_time(sum by (namespace, pod, node) (rate(container_cpu_usage_seconds_total{container!=""}[1m]))[15m:])`,
+		`max_over_time(sum by (namespace, pod, node) (rate(container_cpu_usage_seconds_total{container!=""}[1m]))[15m:])`,
+	}, *queries)
+	assert.Equal(t, watcher.PodMetricsMap{
+		watcher.PodKey("default", "web-1"): {Namespace: "default", Name: "web-1", Node: "worker-1", Metrics: []watcher.Metric{
+			{Name: "container_cpu_usage_seconds_total", Type: watcher.CPU, Operator: watcher.Average, Rollup: watcher.FifteenMinutes, Value: 0.5},
+			{Name: "container_cpu_usage_seconds_total", Type: watcher.CPU, Operator: watcher.Max, Rollup: watcher.FifteenMinutes, Value: 0.5},
+		}},
+	}, pods)
+
+	// Pod metrics are not fetched without pod queries
+	t.Setenv(PromQueriesConfigKey, "")
+	client, err = NewPromClient(watcher.MetricsProviderOpts{Name: watcher.PromClientName, Address: server.URL})
+	require.Nil(t, err)
+	pods, err = client.(watcher.PodMetricsProviderClient).FetchAllPodsMetrics(watcher.CurrentFifteenMinuteWindow())
+	require.Nil(t, err)
+	assert.Nil(t, pods)
+}
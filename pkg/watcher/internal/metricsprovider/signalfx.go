@@ -17,6 +17,7 @@ limitations under the License.
 package metricsprovider
 
 import (
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"errors"
@@ -93,6 +94,10 @@ func (s signalFxClient) Name() string {
 }
 
 func (s signalFxClient) FetchHostMetrics(host string, window *watcher.Window) ([]watcher.Metric, error) {
+	return s.FetchHostMetricsContext(context.Background(), host, window)
+}
+
+func (s signalFxClient) FetchHostMetricsContext(ctx context.Context, host string, window *watcher.Window) ([]watcher.Metric, error) {
 	log.Debugf("fetching metrics for host %v", host)
 	var metrics []watcher.Metric
 	hostFilter := signalFxHostFilter + host + s.hostNameSuffix
@@ -102,17 +107,14 @@ func (s signalFxClient) FetchHostMetrics(host string, window *watcher.Window) ([
 		if err != nil {
 			return metrics, fmt.Errorf("received error when building metric URL: %v", err)
 		}
-		req, _ := http.NewRequest(http.MethodGet, uri.String(), nil)
-		req.Header.Set("X-SF-Token", s.authToken)
-		req.Header.Set("Content-Type", "application/json")
-
+		req := s.requestWithAuthToken(ctx, uri.String())
 		resp, err := s.client.Do(req)
 		if err != nil {
-			return metrics, fmt.Errorf("received error in metric API call: %v", err)
+			return metrics, fmt.Errorf("received error in metric API call: %w", err)
 		}
 		defer resp.Body.Close()
 		if resp.StatusCode != http.StatusOK {
-			return metrics, fmt.Errorf("received status code: %v", resp.StatusCode)
+			return metrics, watcher.NewHTTPStatusError(resp)
 		}
 		var res interface{}
 		err = json.NewDecoder(resp.Body).Decode(&res)
@@ -132,6 +134,10 @@ func (s signalFxClient) FetchHostMetrics(host string, window *watcher.Window) ([
 }
 
 func (s signalFxClient) FetchAllHostsMetrics(window *watcher.Window) (map[string][]watcher.Metric, error) {
+	return s.FetchAllHostsMetricsContext(context.Background(), window)
+}
+
+func (s signalFxClient) FetchAllHostsMetricsContext(ctx context.Context, window *watcher.Window) (map[string][]watcher.Metric, error) {
 	hostFilter := signalFxHostFilter + "*" + s.hostNameSuffix
 	clusterFilter := signalFxClusterFilter + s.clusterName
 	metrics := make(map[string][]watcher.Metric)
@@ -140,14 +146,14 @@ func (s signalFxClient) FetchAllHostsMetrics(window *watcher.Window) (map[string
 		if err != nil {
 			return metrics, fmt.Errorf("received error when building metric URL: %v", err)
 		}
-		req := s.requestWithAuthToken(uri.String())
+		req := s.requestWithAuthToken(ctx, uri.String())
 		metricResp, err := s.client.Do(req)
 		if err != nil {
-			return metrics, fmt.Errorf("received error in metric API call: %v", err)
+			return metrics, fmt.Errorf("received error in metric API call: %w", err)
 		}
 		defer metricResp.Body.Close()
 		if metricResp.StatusCode != http.StatusOK {
-			return metrics, fmt.Errorf("received status code for metric resp: %v", metricResp.StatusCode)
+			return metrics, fmt.Errorf("metric resp: %w", watcher.NewHTTPStatusError(metricResp))
 		}
 		var metricPayload interface{}
 		err = json.NewDecoder(metricResp.Body).Decode(&metricPayload)
@@ -159,14 +165,14 @@ func (s signalFxClient) FetchAllHostsMetrics(window *watcher.Window) (map[string
 		if err != nil {
 			return metrics, fmt.Errorf("received error when building metadata URL: %v", err)
 		}
-		req = s.requestWithAuthToken(uri.String())
+		req = s.requestWithAuthToken(ctx, uri.String())
 		metadataResp, err := s.client.Do(req)
 		if err != nil {
-			return metrics, fmt.Errorf("received error in metadata API call: %v", err)
+			return metrics, fmt.Errorf("received error in metadata API call: %w", err)
 		}
 		defer metadataResp.Body.Close()
 		if metadataResp.StatusCode != http.StatusOK {
-			return metrics, fmt.Errorf("received status code for metadata resp: %v", metadataResp.StatusCode)
+			return metrics, fmt.Errorf("metadata resp: %w", watcher.NewHTTPStatusError(metadataResp))
 		}
 		var metadataPayload interface{}
 		err = json.NewDecoder(metadataResp.Body).Decode(&metadataPayload)
@@ -186,11 +192,15 @@ func (s signalFxClient) FetchAllHostsMetrics(window *watcher.Window) (map[string
 }
 
 func (s signalFxClient) Health() (int, error) {
-	return Ping(s.client, s.signalFxAddress)
+	return s.HealthContext(context.Background())
 }
 
-func (s signalFxClient) requestWithAuthToken(uri string) *http.Request {
-	req, _ := http.NewRequest(http.MethodGet, uri, nil)
+func (s signalFxClient) HealthContext(ctx context.Context) (int, error) {
+	return PingContext(ctx, s.client, s.signalFxAddress)
+}
+
+func (s signalFxClient) requestWithAuthToken(ctx context.Context, uri string) *http.Request {
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
 	req.Header.Set("X-SF-Token", s.authToken)
 	req.Header.Set("Content-Type", "application/json")
 	return req
@@ -199,7 +209,12 @@ func (s signalFxClient) requestWithAuthToken(uri string) *http.Request {
 // Simple ping utility to a given URL
 // Returns -1 if unhealthy, 0 if healthy along with error if any
 func Ping(client http.Client, url string) (int, error) {
-	req, err := http.NewRequest("HEAD", url, nil)
+	return PingContext(context.Background(), client, url)
+}
+
+// PingContext Ping within ctx
+func PingContext(ctx context.Context, client http.Client, url string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, "HEAD", url, nil)
 	if err != nil {
 		return -1, err
 	}
@@ -17,8 +17,14 @@ limitations under the License.
 package watcher
 
 import (
+	"context"
+	"io"
 	"os"
+	"strconv"
 	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
 )
 
 const (
@@ -26,16 +32,40 @@ const (
 	PromClientName     = "Prometheus"
 	SignalFxClientName = "SignalFx"
 	DatadogClientName  = "Datadog"
+	// Merges the metrics of the Providers of MetricsProviderOpts
+	CompositeClientName = "Composite"
+	// Fetches from the first available of the Providers of MetricsProviderOpts
+	FailoverClientName = "Failover"
 
 	MetricsProviderNameKey    = "METRICS_PROVIDER_NAME"
 	MetricsProviderAddressKey = "METRICS_PROVIDER_ADDRESS"
 	MetricsProviderTokenKey   = "METRICS_PROVIDER_TOKEN"
 	MetricsProviderAppKey     = "METRICS_PROVIDER_APP_KEY"
 	InsecureSkipVerify        = "INSECURE_SKIP_VERIFY"
+	// env variable listing the backends of the Failover client in order of preference, e.g. SignalFx,Datadog
+	MetricsProviderFailoverKey = "METRICS_PROVIDER_FAILOVER"
+	// env variable that provides the recovery period of the Failover client, e.g. 10m
+	MetricsProviderRecoveryPeriodKey = "METRICS_PROVIDER_RECOVERY_PERIOD"
+
+	WatcherWindowsKey         = "WATCHER_WINDOWS"
+	WatcherCacheSizeKey       = "WATCHER_CACHE_SIZE"
+	WatcherMaxAgeKey          = "WATCHER_MAX_AGE"
+	WatcherAddressKey         = "WATCHER_ADDRESS"
+	WatcherTLSCertFileKey     = "WATCHER_TLS_CERT_FILE"
+	WatcherTLSKeyFileKey      = "WATCHER_TLS_KEY_FILE"
+	WatcherTLSClientCAFileKey = "WATCHER_TLS_CLIENT_CA_FILE"
+	WatcherNodeMetadataKey    = "WATCHER_NODE_METADATA"
+	WatcherNodeLabelsKey      = "WATCHER_NODE_LABELS"
+	WatcherNodeTaintsKey      = "WATCHER_NODE_TAINTS"
+
+	WatcherFetchMaxAttemptsKey        = "WATCHER_FETCH_MAX_ATTEMPTS"
+	WatcherCircuitBreakerThresholdKey = "WATCHER_CIRCUIT_BREAKER_THRESHOLD"
 )
 
 var (
 	EnvMetricProviderOpts MetricsProviderOpts
+	EnvWatcherOpts        WatcherOpts
+	EnvNodeTaggerOpts     NodeTaggerOpts
 )
 
 func init() {
@@ -53,6 +83,95 @@ func init() {
 	} else {
 		EnvMetricProviderOpts.InsecureSkipVerify = false
 	}
+	if names := splitParam([]string{EnvMetricProviderOpts.Name}); len(names) > 1 {
+		EnvMetricProviderOpts.Name = CompositeClientName
+		for _, name := range names {
+			EnvMetricProviderOpts.Providers = append(EnvMetricProviderOpts.Providers, providerEnvOpts(name))
+		}
+	}
+	if backends, ok := os.LookupEnv(MetricsProviderFailoverKey); ok {
+		EnvMetricProviderOpts.Name = FailoverClientName
+		EnvMetricProviderOpts.Providers = nil
+		for _, name := range splitParam([]string{backends}) {
+			EnvMetricProviderOpts.Providers = append(EnvMetricProviderOpts.Providers, providerEnvOpts(name))
+		}
+	}
+	if recoveryPeriod, ok := os.LookupEnv(MetricsProviderRecoveryPeriodKey); ok {
+		var err error
+		EnvMetricProviderOpts.RecoveryPeriod, err = time.ParseDuration(recoveryPeriod)
+		if err != nil {
+			log.Errorf("unable to parse %v, using default recovery period: %v", MetricsProviderRecoveryPeriodKey, err)
+		}
+	}
+	if windows, ok := os.LookupEnv(WatcherWindowsKey); ok {
+		var err error
+		EnvWatcherOpts.Windows, err = ParseWindowDurations(windows)
+		if err != nil {
+			log.Errorf("unable to parse %v, using default windows: %v", WatcherWindowsKey, err)
+		}
+	}
+	if cacheSize, ok := os.LookupEnv(WatcherCacheSizeKey); ok {
+		var err error
+		EnvWatcherOpts.CacheSize, err = strconv.Atoi(cacheSize)
+		if err != nil {
+			log.Errorf("unable to parse %v, using default cache size: %v", WatcherCacheSizeKey, err)
+		}
+	}
+	if maxAge, ok := os.LookupEnv(WatcherMaxAgeKey); ok {
+		var err error
+		EnvWatcherOpts.MaxAge, err = time.ParseDuration(maxAge)
+		if err != nil {
+			log.Errorf("unable to parse %v, using default max age: %v", WatcherMaxAgeKey, err)
+		}
+	}
+	EnvWatcherOpts.Address, _ = os.LookupEnv(WatcherAddressKey)
+	EnvWatcherOpts.TLSCertFile, _ = os.LookupEnv(WatcherTLSCertFileKey)
+	EnvWatcherOpts.TLSKeyFile, _ = os.LookupEnv(WatcherTLSKeyFileKey)
+	EnvWatcherOpts.TLSClientCAFile, _ = os.LookupEnv(WatcherTLSClientCAFileKey)
+	if maxAttempts, ok := os.LookupEnv(WatcherFetchMaxAttemptsKey); ok {
+		EnvWatcherOpts.RetryPolicy = DefaultRetryPolicy
+		var err error
+		EnvWatcherOpts.RetryPolicy.MaxAttempts, err = strconv.Atoi(maxAttempts)
+		if err != nil {
+			log.Errorf("unable to parse %v, using default retry policy: %v", WatcherFetchMaxAttemptsKey, err)
+			EnvWatcherOpts.RetryPolicy = DefaultRetryPolicy
+		}
+	}
+	if threshold, ok := os.LookupEnv(WatcherCircuitBreakerThresholdKey); ok {
+		EnvWatcherOpts.CircuitBreakerPolicy = DefaultCircuitBreakerPolicy
+		var err error
+		EnvWatcherOpts.CircuitBreakerPolicy.FailureThreshold, err = strconv.Atoi(threshold)
+		if err != nil {
+			log.Errorf("unable to parse %v, using default circuit breaker policy: %v", WatcherCircuitBreakerThresholdKey, err)
+			EnvWatcherOpts.CircuitBreakerPolicy = DefaultCircuitBreakerPolicy
+		}
+	}
+	if nodeMetadata, ok := os.LookupEnv(WatcherNodeMetadataKey); ok {
+		var err error
+		EnvNodeTaggerOpts.Enabled, err = strconv.ParseBool(nodeMetadata)
+		if err != nil {
+			log.Errorf("unable to parse %v, not tagging nodes: %v", WatcherNodeMetadataKey, err)
+		}
+	}
+	if labels, ok := os.LookupEnv(WatcherNodeLabelsKey); ok {
+		EnvNodeTaggerOpts.Labels = splitParam([]string{labels})
+	}
+	if taints, ok := os.LookupEnv(WatcherNodeTaintsKey); ok {
+		EnvNodeTaggerOpts.Taints = splitParam([]string{taints})
+	}
+}
+
+// Returns the options of a provider of the Composite or Failover client. Its address, token and application key are read from
+// the env variables suffixed with its upper case name, e.g. METRICS_PROVIDER_ADDRESS_PROMETHEUS
+func providerEnvOpts(name string) MetricsProviderOpts {
+	suffix := "_" + strings.ToUpper(name)
+	return MetricsProviderOpts{
+		Name:               name,
+		Address:            os.Getenv(MetricsProviderAddressKey + suffix),
+		AuthToken:          os.Getenv(MetricsProviderTokenKey + suffix),
+		ApplicationKey:     os.Getenv(MetricsProviderAppKey + suffix),
+		InsecureSkipVerify: EnvMetricProviderOpts.InsecureSkipVerify,
+	}
 }
 
 // Interface to be implemented by any metrics provider client to interact with Watcher
@@ -68,6 +187,172 @@ type MetricsProviderClient interface {
 	Health() (int, error)
 }
 
+// ContextMetricsProviderClient Context aware version of MetricsProviderClient. Calls should stop once ctx is done,
+// and honour its deadline. Clients which only implement MetricsProviderClient are adapted with WithContext
+type ContextMetricsProviderClient interface {
+	// Return the client name
+	Name() string
+	// Fetch metrics for given host
+	FetchHostMetricsContext(ctx context.Context, host string, window *Window) ([]Metric, error)
+	// Fetch metrics for all hosts
+	FetchAllHostsMetricsContext(ctx context.Context, window *Window) (map[string][]Metric, error)
+	// Get metric provider server health status
+	// Returns 0 if healthy, -1 if unhealthy along with error if any
+	HealthContext(ctx context.Context) (int, error)
+}
+
+// ContextPodMetricsProviderClient Context aware version of PodMetricsProviderClient
+type ContextPodMetricsProviderClient interface {
+	// Fetch metrics for all pods, keyed by namespace/name. Returns nil if the client is not configured for pod metrics
+	FetchAllPodsMetricsContext(ctx context.Context, window *Window) (PodMetricsMap, error)
+}
+
+// PodMetricsProviderClient Implemented by metrics provider clients which can also fetch pod metrics
+type PodMetricsProviderClient interface {
+	// Fetch metrics for all pods, keyed by namespace/name. Returns nil if the client is not configured for pod metrics
+	FetchAllPodsMetrics(window *Window) (PodMetricsMap, error)
+}
+
+// WithContext Returns a context aware client calling client. Calls use the context aware methods of client if it has them.
+// Otherwise calls are not made once ctx is done, but calls in flight are not canceled
+func WithContext(client MetricsProviderClient) ContextMetricsProviderAdapter {
+	if adapter, ok := client.(ContextMetricsProviderAdapter); ok {
+		return adapter
+	}
+	return ContextMetricsProviderAdapter{client: client}
+}
+
+// ContextMetricsProviderAdapter Adapts a MetricsProviderClient, and its pod metrics if supported, to the context aware interfaces.
+// It implements both versions of the interfaces
+type ContextMetricsProviderAdapter struct {
+	client MetricsProviderClient
+}
+
+func (a ContextMetricsProviderAdapter) Name() string {
+	return a.client.Name()
+}
+
+func (a ContextMetricsProviderAdapter) FetchHostMetrics(host string, window *Window) ([]Metric, error) {
+	return a.client.FetchHostMetrics(host, window)
+}
+
+func (a ContextMetricsProviderAdapter) FetchHostMetricsContext(ctx context.Context, host string, window *Window) ([]Metric, error) {
+	if c, ok := a.client.(ContextMetricsProviderClient); ok {
+		return c.FetchHostMetricsContext(ctx, host, window)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return a.client.FetchHostMetrics(host, window)
+}
+
+func (a ContextMetricsProviderAdapter) FetchAllHostsMetrics(window *Window) (map[string][]Metric, error) {
+	return a.client.FetchAllHostsMetrics(window)
+}
+
+func (a ContextMetricsProviderAdapter) FetchAllHostsMetricsContext(ctx context.Context, window *Window) (map[string][]Metric, error) {
+	if c, ok := a.client.(ContextMetricsProviderClient); ok {
+		return c.FetchAllHostsMetricsContext(ctx, window)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return a.client.FetchAllHostsMetrics(window)
+}
+
+// Close Closes the adapted client if it is an io.Closer, e.g. to stop its background work
+func (a ContextMetricsProviderAdapter) Close() error {
+	if closer, ok := a.client.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// SupportsPodMetrics Returns true if the adapted client supports pod metrics
+func (a ContextMetricsProviderAdapter) SupportsPodMetrics() bool {
+	switch a.client.(type) {
+	case ContextPodMetricsProviderClient, PodMetricsProviderClient:
+		return true
+	}
+	return false
+}
+
+// FetchAllPodsMetrics Returns nil if the adapted client does not support pod metrics
+func (a ContextMetricsProviderAdapter) FetchAllPodsMetrics(window *Window) (PodMetricsMap, error) {
+	return a.FetchAllPodsMetricsContext(context.Background(), window)
+}
+
+// FetchAllPodsMetricsContext Returns nil if the adapted client does not support pod metrics
+func (a ContextMetricsProviderAdapter) FetchAllPodsMetricsContext(ctx context.Context, window *Window) (PodMetricsMap, error) {
+	switch c := a.client.(type) {
+	case ContextPodMetricsProviderClient:
+		return c.FetchAllPodsMetricsContext(ctx, window)
+	case PodMetricsProviderClient:
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return c.FetchAllPodsMetrics(window)
+	}
+	return nil, nil
+}
+
+func (a ContextMetricsProviderAdapter) Health() (int, error) {
+	return a.client.Health()
+}
+
+func (a ContextMetricsProviderAdapter) HealthContext(ctx context.Context) (int, error) {
+	if c, ok := a.client.(ContextMetricsProviderClient); ok {
+		return c.HealthContext(ctx)
+	}
+	if err := ctx.Err(); err != nil {
+		return -1, err
+	}
+	return a.client.Health()
+}
+
+// NodeTagger Returns the tags and metadata of nodes, independently of the metrics provider
+type NodeTagger interface {
+	// Returns the tags, metadata and labels of the node named host, false if the node is unknown
+	NodeInfo(host string) (NodeInfo, bool)
+}
+
+// EndpointResolver Resolves the addresses of watcher replicas for the service client, e.g. from a Kubernetes Service
+type EndpointResolver interface {
+	// Returns the base URLs of the ready replicas, e.g. http://10.0.0.1:2020
+	Endpoints() ([]string, error)
+}
+
+// SourceProviderClient Implemented by metrics provider clients which fetch from one of several backends,
+// so that the Watcher records the backend which supplied the metrics of each window in WatcherMetrics.Source
+type SourceProviderClient interface {
+	// Returns the name of the backend which supplied the last metrics of the window, empty if unknown
+	FetchSource(window *Window) string
+}
+
+// PartialMetricsError Returned by a metrics provider client along with the metrics it fetched when only some of
+// its queries failed. The Watcher caches such partial metrics instead of discarding them
+type PartialMetricsError struct {
+	Err error
+}
+
+func (e *PartialMetricsError) Error() string {
+	return "partial metrics: " + e.Err.Error()
+}
+
+func (e *PartialMetricsError) Unwrap() error {
+	return e.Err
+}
+
+// Options of the Kubernetes node tagger
+type NodeTaggerOpts struct {
+	// Tag nodes with the metadata and selected labels and taints of their Kubernetes node objects
+	Enabled bool
+	// Keys of the node labels copied to tags, all labels if it contains "*"
+	Labels []string
+	// Keys of the node taints copied to tags, all taints if it contains "*"
+	Taints []string
+}
+
 // Generic metrics provider options
 type MetricsProviderOpts struct {
 	Name               string
@@ -75,4 +360,9 @@ type MetricsProviderOpts struct {
 	AuthToken          string
 	ApplicationKey     string
 	InsecureSkipVerify bool
+	// Providers of the Composite client, in order of precedence, or backends of the Failover client, in order of preference.
+	// Highest first
+	Providers []MetricsProviderOpts
+	// Period after which the Failover client tries to switch back to preferred backends, 5m if not positive
+	RecoveryPeriod time.Duration
 }
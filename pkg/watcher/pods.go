@@ -0,0 +1,171 @@
+/*
+Copyright 2020 PayPal
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// Serves the latest pod and namespace metrics of a window
+	PodsUrl = "/watcher/pods"
+)
+
+// PodKey Returns the key of a pod in a PodMetricsMap
+func PodKey(namespace string, name string) string {
+	return namespace + "/" + name
+}
+
+// GetPodMetrics Returns the latest pod and namespace metrics of the query window, falling back like GetLatestWatcherMetrics,
+// with only the pods, namespaces and metrics selected by the query. The default window is used if the query has none.
+// Start() should be called before calling this.
+func (w *Watcher) GetPodMetrics(query MetricsQuery) (*WatcherMetrics, error) {
+	window := query.Window
+	if window == "" {
+		window = w.defaultWindow()
+	}
+	metrics, err := w.GetLatestWatcherMetrics(window)
+	if metrics == nil {
+		return nil, err
+	}
+	filtered, filterErr := query.FilterPods(metrics)
+	if filterErr != nil {
+		return nil, filterErr
+	}
+	filtered.Stale = metrics.Stale
+	return filtered, err
+}
+
+// HTTP Handler for PodsUrl endpoint. It accepts the query parameters of the BaseUrl endpoint, where hosts select
+// the nodes pods run on, and the namespace parameter
+func (w *Watcher) podsHandler(resp http.ResponseWriter, r *http.Request) {
+	resp.Header().Set("Content-Type", "application/json")
+	resp.Header().Set("Vary", "Accept-Encoding")
+
+	query, err := ParseMetricsQuery(r.URL.Query())
+	if err == nil && query.Window != "" && !w.isWatched(query.Window) {
+		err = errNotWatched(query.Window)
+	}
+	if err != nil {
+		resp.WriteHeader(http.StatusBadRequest)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	metrics, err := w.GetPodMetrics(query)
+	if metrics == nil || (err != nil && !errors.Is(err, ErrStaleMetrics)) {
+		if err != nil {
+			resp.WriteHeader(http.StatusInternalServerError)
+			log.Error(err)
+			return
+		}
+		resp.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if len(metrics.Data.PodMetricsMap) == 0 && len(metrics.Data.NamespaceMetricsMap) == 0 {
+		resp.WriteHeader(http.StatusNotFound)
+		resp.Write([]byte("No pod metrics found"))
+		return
+	}
+
+	status := http.StatusOK
+	if metrics.Stale {
+		status = http.StatusServiceUnavailable
+	}
+	encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+	err = writeEncoded(resp, encoding, status, func(writer io.Writer) error {
+		return encodeWatcherMetrics(writer, metrics)
+	})
+	if err != nil {
+		log.Error(err)
+	}
+}
+
+// Sums up the AVG and Latest metrics of pods per namespace, metric name and type. Other operators are not additive
+func sumNamespaceMetrics(pods PodMetricsMap) NamespaceMetricsMap {
+	type key struct {
+		name, metricType, operator string
+	}
+	sums := make(map[string]map[key]Metric)
+	for _, pod := range pods {
+		namespaceSums, ok := sums[pod.Namespace]
+		if !ok {
+			namespaceSums = make(map[key]Metric)
+			sums[pod.Namespace] = namespaceSums
+		}
+		for _, metric := range pod.Metrics {
+			if metric.Operator != Average && metric.Operator != Latest {
+				continue
+			}
+			k := key{metric.Name, metric.Type, metric.Operator}
+			sum, ok := namespaceSums[k]
+			if !ok {
+				sum = Metric{Name: metric.Name, Type: metric.Type, Operator: metric.Operator, Rollup: metric.Rollup}
+			}
+			sum.Value += metric.Value
+			namespaceSums[k] = sum
+		}
+	}
+
+	namespaces := make(NamespaceMetricsMap, len(sums))
+	for namespace, namespaceSums := range sums {
+		metrics := make([]Metric, 0, len(namespaceSums))
+		for _, metric := range namespaceSums {
+			metrics = append(metrics, metric)
+		}
+		sort.Slice(metrics, func(i, j int) bool {
+			if metrics[i].Type != metrics[j].Type {
+				return metrics[i].Type < metrics[j].Type
+			}
+			if metrics[i].Operator != metrics[j].Operator {
+				return metrics[i].Operator < metrics[j].Operator
+			}
+			return metrics[i].Name < metrics[j].Name
+		})
+		namespaces[namespace] = NamespaceMetrics{Metrics: metrics}
+	}
+	return namespaces
+}
+
+func (m PodMetricsMap) deepCopy() PodMetricsMap {
+	if m == nil {
+		return nil
+	}
+	pods := make(PodMetricsMap, len(m))
+	for key, pod := range m {
+		pod.Metrics = append([]Metric(nil), pod.Metrics...)
+		pods[key] = pod
+	}
+	return pods
+}
+
+func (m NamespaceMetricsMap) deepCopy() NamespaceMetricsMap {
+	if m == nil {
+		return nil
+	}
+	namespaces := make(NamespaceMetricsMap, len(m))
+	for namespace, metrics := range m {
+		metrics.Metrics = append([]Metric(nil), metrics.Metrics...)
+		namespaces[namespace] = metrics
+	}
+	return namespaces
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2020 PayPal
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/francoispqt/gojay"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testPodMetricsMap = PodMetricsMap{
+	PodKey("default", "web-1"): {Namespace: "default", Name: "web-1", Node: FirstNode, Metrics: []Metric{
+		{Type: CPU, Operator: Average, Rollup: FifteenMinutes, Value: 0.5},
+		{Type: CPU, Operator: Max, Rollup: FifteenMinutes, Value: 0.9},
+		{Type: Memory, Operator: Average, Rollup: FifteenMinutes, Value: 1 << 30},
+	}},
+	PodKey("default", "web-2"): {Namespace: "default", Name: "web-2", Node: SecondNode, Metrics: []Metric{
+		{Type: CPU, Operator: Average, Rollup: FifteenMinutes, Value: 0.25},
+		{Type: CPU, Operator: Max, Rollup: FifteenMinutes, Value: 0.5},
+		{Type: Memory, Operator: Average, Rollup: FifteenMinutes, Value: 1 << 29},
+	}},
+	PodKey("batch", "job-1"): {Namespace: "batch", Name: "job-1", Node: FirstNode, Metrics: []Metric{
+		{Type: CPU, Operator: Average, Rollup: FifteenMinutes, Value: 2},
+	}},
+}
+
+// Returns the pod metrics of testPodMetricsMap, or an error if failing
+type podsClient struct {
+	MetricsProviderClient
+	failing bool
+}
+
+func (c podsClient) FetchAllPodsMetrics(window *Window) (PodMetricsMap, error) {
+	if c.failing {
+		return nil, errors.New("pod metrics unavailable")
+	}
+	return testPodMetricsMap.deepCopy(), nil
+}
+
+func servePods(t *testing.T, podsWatcher *Watcher, url string) *httptest.ResponseRecorder {
+	req, err := http.NewRequest("GET", url, nil)
+	require.Nil(t, err)
+	rr := httptest.NewRecorder()
+	podsWatcher.Handler().ServeHTTP(rr, req)
+	return rr
+}
+
+func TestWatcherPodMetrics(t *testing.T) {
+	podsWatcher := NewWatcher(podsClient{MetricsProviderClient: NewTestMetricsServerClient()}, WatcherOpts{})
+	podsWatcher.isStarted = true
+	podsWatcher.fetchOnce(context.Background(), 15*time.Minute)
+
+	rr := servePods(t, podsWatcher, PodsUrl)
+	require.Equal(t, http.StatusOK, rr.Code)
+	metrics := &WatcherMetrics{Data: Data{NodeMetricsMap: make(NodeMetricsMap)}}
+	require.Nil(t, gojay.UnmarshalJSONObject(rr.Body.Bytes(), metrics))
+	assert.Empty(t, metrics.Data.NodeMetricsMap)
+	assert.Equal(t, testPodMetricsMap, metrics.Data.PodMetricsMap)
+	assert.Equal(t, NamespaceMetricsMap{
+		"default": {Metrics: []Metric{
+			{Type: CPU, Operator: Average, Rollup: FifteenMinutes, Value: 0.75},
+			{Type: Memory, Operator: Average, Rollup: FifteenMinutes, Value: 1<<30 + 1<<29},
+		}},
+		"batch": {Metrics: []Metric{
+			{Type: CPU, Operator: Average, Rollup: FifteenMinutes, Value: 2},
+		}},
+	}, metrics.Data.NamespaceMetricsMap)
+
+	// Pods are selected by namespace and node, namespaces only by namespace
+	rr = servePods(t, podsWatcher, PodsUrl+"?namespace=default&host="+FirstNode+"&type=CPU&operator=MAX")
+	require.Equal(t, http.StatusOK, rr.Code)
+	metrics = &WatcherMetrics{Data: Data{NodeMetricsMap: make(NodeMetricsMap)}}
+	require.Nil(t, gojay.UnmarshalJSONObject(rr.Body.Bytes(), metrics))
+	assert.Equal(t, PodMetricsMap{
+		PodKey("default", "web-1"): {Namespace: "default", Name: "web-1", Node: FirstNode, Metrics: []Metric{
+			{Type: CPU, Operator: Max, Rollup: FifteenMinutes, Value: 0.9},
+		}},
+	}, metrics.Data.PodMetricsMap)
+	assert.Empty(t, metrics.Data.NamespaceMetricsMap)
+
+	assert.Equal(t, http.StatusNotFound, servePods(t, podsWatcher, PodsUrl+"?namespace=unknown").Code)
+	assert.Equal(t, http.StatusBadRequest, servePods(t, podsWatcher, PodsUrl+"?window=1h").Code)
+
+	// Node metrics are served without pod metrics
+	rr = servePods(t, podsWatcher, BaseUrl)
+	require.Equal(t, http.StatusOK, rr.Code)
+	metrics = &WatcherMetrics{Data: Data{NodeMetricsMap: make(NodeMetricsMap)}}
+	require.Nil(t, gojay.UnmarshalJSONObject(rr.Body.Bytes(), metrics))
+	assert.NotEmpty(t, metrics.Data.NodeMetricsMap)
+	assert.Nil(t, metrics.Data.PodMetricsMap)
+	assert.NotContains(t, rr.Body.String(), "PodMetricsMap")
+
+	// Cached pod metrics are not served from a stopped Watcher
+	podsWatcher.isStarted = false
+	_, err := podsWatcher.GetPodMetrics(MetricsQuery{})
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusInternalServerError, servePods(t, podsWatcher, PodsUrl).Code)
+}
+
+func TestWatcherHistoryWithoutPodMetrics(t *testing.T) {
+	podsWatcher := NewWatcher(podsClient{MetricsProviderClient: NewTestMetricsServerClient()}, WatcherOpts{})
+	podsWatcher.isStarted = true
+	podsWatcher.fetchOnce(context.Background(), 15*time.Minute)
+
+	// Pod metrics are cached, but history entries hold node metrics only, like the latest metrics
+	cached, err := podsWatcher.GetWatcherMetricsHistory(FifteenMinutes, 0)
+	require.Nil(t, err)
+	require.Len(t, cached, 1)
+	assert.NotEmpty(t, cached[0].Data.PodMetricsMap)
+	history, err := podsWatcher.QueryWatcherMetricsHistory(MetricsQuery{Hosts: []string{FirstNode}}, 0)
+	require.Nil(t, err)
+	require.Len(t, history, 1)
+	assert.Len(t, history[0].Data.NodeMetricsMap, 1)
+	assert.Nil(t, history[0].Data.PodMetricsMap)
+	assert.Nil(t, history[0].Data.NamespaceMetricsMap)
+
+	rr := servePods(t, podsWatcher, HistoryUrl)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.NotContains(t, rr.Body.String(), "PodMetricsMap")
+	assert.NotContains(t, rr.Body.String(), "NamespaceMetricsMap")
+}
+
+func TestWatcherPodMetricsFailure(t *testing.T) {
+	podsWatcher := NewWatcher(podsClient{MetricsProviderClient: NewTestMetricsServerClient(), failing: true}, WatcherOpts{})
+	podsWatcher.isStarted = true
+	podsWatcher.fetchOnce(context.Background(), 15*time.Minute)
+
+	// Node metrics are still cached
+	latest, err := podsWatcher.GetLatestWatcherMetrics(FifteenMinutes)
+	require.Nil(t, err)
+	assert.NotEmpty(t, latest.Data.NodeMetricsMap)
+	assert.Equal(t, http.StatusNotFound, servePods(t, podsWatcher, PodsUrl).Code)
+}
+
+func TestWatcherWithoutPodMetrics(t *testing.T) {
+	assert.Nil(t, w.podClient)
+	assert.Equal(t, http.StatusNotFound, servePods(t, w, PodsUrl).Code)
+}
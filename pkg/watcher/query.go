@@ -0,0 +1,294 @@
+/*
+Copyright 2020 PayPal
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	// Query parameters of the BaseUrl endpoint
+	WindowParam   = "window"
+	HostParam     = "host"
+	HostsParam    = "hosts"
+	TypeParam     = "type"
+	OperatorParam = "operator"
+	// Kubernetes label selector matched against node labels, e.g. topology.kubernetes.io/zone=us-east-1a
+	LabelSelectorParam = "labelSelector"
+	// Query parameter of the PodsUrl endpoint
+	NamespaceParam = "namespace"
+	// Query parameter of the HistoryUrl endpoint, in unix seconds
+	SinceParam = "since"
+)
+
+// MetricsQuery Selects a window and the subset of cached metrics to return from it
+type MetricsQuery struct {
+	// Window duration such as 15m, the default window if empty
+	Window string
+	// Host names to include
+	Hosts []string
+	// Regular expression matched against the start of host names to include, so a plain prefix works too.
+	// Hosts matching either Hosts or HostsPattern are included. All hosts are included if both are empty
+	HostsPattern string
+	// Metric types to include, such as CPU or Memory. All types are included if empty
+	Types []string
+	// Metric operators to include, such as AVG or STD. All operators are included if empty
+	Operators []string
+	// Label selector matched against the labels of hosts, known when nodes are tagged. All hosts are included if empty
+	LabelSelector string
+	// Namespaces of pods to include, only used for pod metrics. All namespaces are included if empty
+	Namespaces []string
+}
+
+// ParseMetricsQuery Parses a MetricsQuery from query parameters. Multiple values can be given either
+// by repeating a parameter or as a comma separated list
+func ParseMetricsQuery(values url.Values) (MetricsQuery, error) {
+	query := MetricsQuery{
+		Window:        values.Get(WindowParam),
+		Hosts:         splitParam(values[HostParam]),
+		HostsPattern:  values.Get(HostsParam),
+		Types:         splitParam(values[TypeParam]),
+		Operators:     splitParam(values[OperatorParam]),
+		LabelSelector: values.Get(LabelSelectorParam),
+		Namespaces:    splitParam(values[NamespaceParam]),
+	}
+	if query.Window != "" {
+		if _, err := ParseWindowDuration(query.Window); err != nil {
+			return query, err
+		}
+	}
+	if _, err := query.hostsRegexp(); err != nil {
+		return query, err
+	}
+	if _, err := query.labelSelector(); err != nil {
+		return query, err
+	}
+	return query, nil
+}
+
+// Values Returns the query parameters of the query, the inverse of ParseMetricsQuery
+func (q MetricsQuery) Values() url.Values {
+	values := url.Values{}
+	if q.Window != "" {
+		values.Set(WindowParam, q.Window)
+	}
+	for _, host := range q.Hosts {
+		values.Add(HostParam, host)
+	}
+	if q.HostsPattern != "" {
+		values.Set(HostsParam, q.HostsPattern)
+	}
+	if len(q.Types) > 0 {
+		values.Set(TypeParam, strings.Join(q.Types, ","))
+	}
+	if len(q.Operators) > 0 {
+		values.Set(OperatorParam, strings.Join(q.Operators, ","))
+	}
+	if q.LabelSelector != "" {
+		values.Set(LabelSelectorParam, q.LabelSelector)
+	}
+	if len(q.Namespaces) > 0 {
+		values.Set(NamespaceParam, strings.Join(q.Namespaces, ","))
+	}
+	return values
+}
+
+// Returns true if the query selects a subset of hosts
+func (q MetricsQuery) selectsHosts() bool {
+	return len(q.Hosts) > 0 || q.HostsPattern != ""
+}
+
+// SelectsNodes Returns true if the query selects a subset of nodes, by host name or labels
+func (q MetricsQuery) SelectsNodes() bool {
+	return q.selectsHosts() || q.LabelSelector != ""
+}
+
+func (q MetricsQuery) hostsRegexp() (*regexp.Regexp, error) {
+	if q.HostsPattern == "" {
+		return nil, nil
+	}
+	re, err := regexp.Compile("^(?:" + q.HostsPattern + ")")
+	if err != nil {
+		return nil, fmt.Errorf("invalid hosts pattern %q: %v", q.HostsPattern, err)
+	}
+	return re, nil
+}
+
+func (q MetricsQuery) labelSelector() (labels.Selector, error) {
+	selector, err := labels.Parse(q.LabelSelector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid label selector %q: %v", q.LabelSelector, err)
+	}
+	return selector, nil
+}
+
+// Filter Returns a copy of metrics with only the hosts and metrics selected by the query.
+// Hosts left without any selected metrics are dropped
+func (q MetricsQuery) Filter(metrics *WatcherMetrics) (*WatcherMetrics, error) {
+	hostSelected, err := q.hostSelector()
+	if err != nil {
+		return nil, err
+	}
+	selector, err := q.labelSelector()
+	if err != nil {
+		return nil, err
+	}
+
+	nodeMetricsMap := make(map[string]NodeMetrics)
+	for host, nodeMetrics := range metrics.Data.NodeMetricsMap {
+		if !hostSelected(host) || !selector.Matches(labels.Set(nodeMetrics.nodeLabels())) {
+			continue
+		}
+		var ok bool
+		if nodeMetrics.Metrics, ok = q.selectMetrics(nodeMetrics.Metrics); !ok {
+			continue
+		}
+		nodeMetricsMap[host] = nodeMetrics
+	}
+
+	return &WatcherMetrics{
+		Timestamp: metrics.Timestamp,
+		Window:    metrics.Window,
+		Source:    metrics.Source,
+		Data:      Data{NodeMetricsMap: nodeMetricsMap},
+	}, nil
+}
+
+// FilterPods Returns a copy of metrics with only the pods and namespaces selected by the query, and no node metrics.
+// Hosts select the nodes pods run on, so namespaces are not filtered by hosts
+func (q MetricsQuery) FilterPods(metrics *WatcherMetrics) (*WatcherMetrics, error) {
+	hostSelected, err := q.hostSelector()
+	if err != nil {
+		return nil, err
+	}
+
+	podMetricsMap := make(PodMetricsMap)
+	for key, podMetrics := range metrics.Data.PodMetricsMap {
+		if !containsString(q.Namespaces, podMetrics.Namespace) || !hostSelected(podMetrics.Node) {
+			continue
+		}
+		var ok bool
+		if podMetrics.Metrics, ok = q.selectMetrics(podMetrics.Metrics); !ok {
+			continue
+		}
+		podMetricsMap[key] = podMetrics
+	}
+	namespaceMetricsMap := make(NamespaceMetricsMap)
+	for namespace, namespaceMetrics := range metrics.Data.NamespaceMetricsMap {
+		if !containsString(q.Namespaces, namespace) {
+			continue
+		}
+		var ok bool
+		if namespaceMetrics.Metrics, ok = q.selectMetrics(namespaceMetrics.Metrics); !ok {
+			continue
+		}
+		namespaceMetricsMap[namespace] = namespaceMetrics
+	}
+
+	return &WatcherMetrics{
+		Timestamp: metrics.Timestamp,
+		Window:    metrics.Window,
+		Source:    metrics.Source,
+		Data: Data{
+			NodeMetricsMap:      NodeMetricsMap{},
+			PodMetricsMap:       podMetricsMap,
+			NamespaceMetricsMap: namespaceMetricsMap,
+		},
+	}, nil
+}
+
+// Returns all labels of the node if it was tagged by this Watcher, the labels of its tags otherwise
+func (m NodeMetrics) nodeLabels() map[string]string {
+	if m.labels != nil {
+		return m.labels
+	}
+	return m.Tags.Labels
+}
+
+// Returns a function which returns true if a host is selected by the query
+func (q MetricsQuery) hostSelector() (func(host string) bool, error) {
+	hostsRegexp, err := q.hostsRegexp()
+	if err != nil {
+		return nil, err
+	}
+	hosts := make(map[string]bool, len(q.Hosts))
+	for _, host := range q.Hosts {
+		hosts[host] = true
+	}
+	return func(host string) bool {
+		return !q.selectsHosts() || hosts[host] || (hostsRegexp != nil && hostsRegexp.MatchString(host))
+	}, nil
+}
+
+// Returns the metrics of the types and operators selected by the query, and false if the query selects
+// types or operators and none of the metrics match
+func (q MetricsQuery) selectMetrics(metrics []Metric) ([]Metric, bool) {
+	if len(q.Types) == 0 && len(q.Operators) == 0 {
+		return metrics, true
+	}
+	var selected []Metric
+	for _, metric := range metrics {
+		if containsFold(q.Types, metric.Type) && containsFold(q.Operators, metric.Operator) {
+			selected = append(selected, metric)
+		}
+	}
+	return selected, len(selected) > 0
+}
+
+// Splits comma separated values of a repeated query parameter
+func splitParam(values []string) []string {
+	var split []string
+	for _, value := range values {
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				split = append(split, v)
+			}
+		}
+	}
+	return split
+}
+
+// Returns true if values is empty or contains value
+func containsString(values []string, value string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns true if values is empty or contains value, ignoring case
+func containsFold(values []string, value string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
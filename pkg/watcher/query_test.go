@@ -0,0 +1,95 @@
+/*
+Copyright 2020 PayPal
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var queryTestMetrics = WatcherMetrics{
+	Timestamp: 1556987522,
+	Window:    Window{Duration: FifteenMinutes, Start: 1556986622, End: 1556987522},
+	Source:    TestServerClientName,
+	Data: Data{NodeMetricsMap: NodeMetricsMap{
+		"worker-1": {Metrics: []Metric{
+			{Name: "cpu", Type: CPU, Operator: Average, Value: 20},
+			{Name: "cpu", Type: CPU, Operator: Std, Value: 5},
+			{Name: "mem", Type: Memory, Operator: Average, Value: 40},
+		}},
+		"worker-2": {Metrics: []Metric{
+			{Name: "mem", Type: Memory, Operator: Average, Value: 60},
+		}},
+		"master-1": {Metrics: []Metric{
+			{Name: "cpu", Type: CPU, Operator: Average, Value: 10},
+		}},
+	}},
+}
+
+func TestParseMetricsQuery(t *testing.T) {
+	values, err := url.ParseQuery("window=5m&host=worker-1&host=worker-2,master-1&hosts=work&type=CPU,Memory&operator=AVG&labelSelector=zone+in+(a,b)")
+	require.Nil(t, err)
+	query, err := ParseMetricsQuery(values)
+	require.Nil(t, err)
+	assert.Equal(t, MetricsQuery{
+		Window:        FiveMinutes,
+		Hosts:         []string{"worker-1", "worker-2", "master-1"},
+		HostsPattern:  "work",
+		Types:         []string{CPU, Memory},
+		Operators:     []string{Average},
+		LabelSelector: "zone in (a,b)",
+	}, query)
+
+	roundTrip, err := ParseMetricsQuery(query.Values())
+	require.Nil(t, err)
+	assert.Equal(t, query, roundTrip)
+
+	_, err = ParseMetricsQuery(url.Values{WindowParam: {"5 minutes"}})
+	assert.NotNil(t, err)
+	_, err = ParseMetricsQuery(url.Values{HostsParam: {"worker-("}})
+	assert.NotNil(t, err)
+	_, err = ParseMetricsQuery(url.Values{LabelSelectorParam: {"zone in a"}})
+	assert.NotNil(t, err)
+}
+
+func TestMetricsQueryFilter(t *testing.T) {
+	filtered, err := MetricsQuery{}.Filter(&queryTestMetrics)
+	require.Nil(t, err)
+	assert.Equal(t, &queryTestMetrics, filtered)
+
+	filtered, err = MetricsQuery{Hosts: []string{"master-1"}, HostsPattern: "worker-[2-9]"}.Filter(&queryTestMetrics)
+	require.Nil(t, err)
+	assert.Len(t, filtered.Data.NodeMetricsMap, 2)
+	assert.Contains(t, filtered.Data.NodeMetricsMap, "master-1")
+	assert.Contains(t, filtered.Data.NodeMetricsMap, "worker-2")
+
+	// The pattern is anchored at the start of host names
+	filtered, err = MetricsQuery{HostsPattern: "1"}.Filter(&queryTestMetrics)
+	require.Nil(t, err)
+	assert.Empty(t, filtered.Data.NodeMetricsMap)
+
+	filtered, err = MetricsQuery{Types: []string{"cpu"}, Operators: []string{Average}}.Filter(&queryTestMetrics)
+	require.Nil(t, err)
+	assert.Len(t, filtered.Data.NodeMetricsMap, 2)
+	assert.Equal(t, []Metric{{Name: "cpu", Type: CPU, Operator: Average, Value: 20}}, filtered.Data.NodeMetricsMap["worker-1"].Metrics)
+	assert.Equal(t, queryTestMetrics.Timestamp, filtered.Timestamp)
+	assert.Equal(t, queryTestMetrics.Window, filtered.Window)
+	assert.Equal(t, queryTestMetrics.Source, filtered.Source)
+}
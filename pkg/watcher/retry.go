@@ -0,0 +1,218 @@
+/*
+Copyright 2020 PayPal
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+var (
+	// DefaultRetryPolicy is used by the Watcher and the service client when none is configured
+	DefaultRetryPolicy = RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Second, MaxBackoff: 10 * time.Second, Jitter: 0.5}
+	// DefaultCircuitBreakerPolicy is used by the Watcher and the service client when none is configured
+	DefaultCircuitBreakerPolicy = CircuitBreakerPolicy{FailureThreshold: 5, OpenDuration: 2 * time.Minute}
+	// ErrCircuitOpen is returned instead of calling a server after too many consecutive failures
+	ErrCircuitOpen = errors.New("circuit breaker is open")
+)
+
+// RetryPolicy Retries calls failing with retryable errors, with exponential backoff and jitter
+type RetryPolicy struct {
+	// Maximum number of attempts, including the first one. Calls are not retried if not greater than 1
+	MaxAttempts int
+	// Backoff before the first retry, doubled for every further retry up to MaxBackoff
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Fraction of the backoff which is randomised, between 0 and 1, so that clients do not retry in lockstep
+	Jitter float64
+}
+
+// CircuitBreakerPolicy Options of a CircuitBreaker
+type CircuitBreakerPolicy struct {
+	// Number of consecutive failures which open the circuit. The circuit never opens if not positive
+	FailureThreshold int
+	// Duration the circuit stays open, before a single trial call is let through
+	OpenDuration time.Duration
+}
+
+// RetryableError Marks an error as retryable, e.g. a server error reported by a metrics provider API
+type RetryableError struct {
+	Err error
+}
+
+func (e *RetryableError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RetryableError) Unwrap() error {
+	return e.Err
+}
+
+// HTTPStatusError An unexpected status code received from an HTTP server. It is retryable for 429 and 5xx
+type HTTPStatusError struct {
+	StatusCode int
+	// Delay requested by the server with the Retry-After header, if any
+	RetryAfter time.Duration
+}
+
+// NewHTTPStatusError Returns the error of an unexpected response
+func NewHTTPStatusError(resp *http.Response) *HTTPStatusError {
+	err := &HTTPStatusError{StatusCode: resp.StatusCode}
+	if seconds, parseErr := strconv.Atoi(resp.Header.Get("Retry-After")); parseErr == nil && seconds > 0 {
+		err.RetryAfter = time.Duration(seconds) * time.Second
+	}
+	return err
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("received status code %v", e.StatusCode)
+}
+
+// IsRetryable Returns true if a call failing with err may succeed when retried: on timeouts, refused or reset
+// connections, 429 and 5xx status codes, and RetryableError. Other errors are permanent, as are partial metrics,
+// which are cached rather than retried, and canceled contexts
+func IsRetryable(err error) bool {
+	var partialErr *PartialMetricsError
+	if err == nil || errors.As(err, &partialErr) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var retryableErr *RetryableError
+	if errors.As(err, &retryableErr) {
+		return true
+	}
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode >= http.StatusInternalServerError
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, context.DeadlineExceeded) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET)
+}
+
+// Do Calls f until it succeeds, fails with a permanent error, the policy allows no more attempts or ctx is done.
+// Calls are only made while the circuit breaker, if not nil, is closed. Returns the error of the last call
+func (p RetryPolicy) Do(ctx context.Context, breaker *CircuitBreaker, f func() error) error {
+	for attempt := 1; ; attempt++ {
+		if err := breaker.Allow(); err != nil {
+			return err
+		}
+		err := f()
+		breaker.Record(err)
+		if !IsRetryable(err) || attempt >= p.MaxAttempts {
+			return err
+		}
+		timer := time.NewTimer(p.backoff(attempt, err))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// Returns the backoff after a failed attempt, at least the delay requested by the server if any
+func (p RetryPolicy) backoff(attempt int, err error) time.Duration {
+	backoff := float64(p.InitialBackoff) * math.Pow(2, float64(attempt-1))
+	if p.MaxBackoff > 0 {
+		backoff = math.Min(backoff, float64(p.MaxBackoff))
+	}
+	jitter := math.Min(math.Max(p.Jitter, 0), 1)
+	backoff -= backoff * jitter * rand.Float64()
+
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) && float64(statusErr.RetryAfter) > backoff {
+		return statusErr.RetryAfter
+	}
+	return time.Duration(backoff)
+}
+
+// CircuitBreaker Stops calls to a failing server after consecutive failures, giving it time to recover.
+// Once the circuit was open for the open duration, a single trial call is let through, which closes
+// the circuit if it succeeds and opens it again otherwise. A nil CircuitBreaker is always closed
+type CircuitBreaker struct {
+	policy   CircuitBreakerPolicy
+	mutex    sync.Mutex
+	failures int       // Consecutive failures
+	openedAt time.Time // Time the circuit opened, zero if closed
+	trial    bool      // A trial call is in flight
+}
+
+func NewCircuitBreaker(policy CircuitBreakerPolicy) *CircuitBreaker {
+	return &CircuitBreaker{policy: policy}
+}
+
+// Allow Returns ErrCircuitOpen if calls are not allowed
+func (b *CircuitBreaker) Allow() error {
+	if b == nil {
+		return nil
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.openedAt.IsZero() {
+		return nil
+	}
+	if b.trial || time.Since(b.openedAt) < b.policy.OpenDuration {
+		return ErrCircuitOpen
+	}
+	b.trial = true
+	return nil
+}
+
+// Record Records the result of an allowed call. Partial metrics are a success, as the server is reachable
+func (b *CircuitBreaker) Record(err error) {
+	if b == nil {
+		return
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.trial = false
+	var partialErr *PartialMetricsError
+	if err == nil || errors.As(err, &partialErr) {
+		b.failures = 0
+		b.openedAt = time.Time{}
+		return
+	}
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	b.failures++
+	if !b.openedAt.IsZero() || (b.policy.FailureThreshold > 0 && b.failures >= b.policy.FailureThreshold) {
+		b.openedAt = time.Now()
+	}
+}
+
+// Open Returns true if the circuit is open
+func (b *CircuitBreaker) Open() bool {
+	if b == nil {
+		return false
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return !b.openedAt.IsZero()
+}
@@ -0,0 +1,183 @@
+/*
+Copyright 2020 PayPal
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testRetryPolicy = RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond, Jitter: 0.5}
+
+func TestIsRetryable(t *testing.T) {
+	for _, err := range []error{
+		&HTTPStatusError{StatusCode: http.StatusTooManyRequests},
+		fmt.Errorf("metric resp: %w", &HTTPStatusError{StatusCode: http.StatusBadGateway}),
+		&url.Error{Op: "Get", URL: "http://watcher", Err: os.ErrDeadlineExceeded},
+		context.DeadlineExceeded,
+		syscall.ECONNREFUSED,
+		&RetryableError{Err: errors.New("server error")},
+		errors.Join(errors.New("bad query"), &RetryableError{Err: errors.New("server error")}),
+	} {
+		assert.True(t, IsRetryable(err), err.Error())
+	}
+	for _, err := range []error{
+		nil,
+		&HTTPStatusError{StatusCode: http.StatusUnauthorized},
+		errors.New("unexpected payload"),
+		context.Canceled,
+		&PartialMetricsError{Err: context.DeadlineExceeded},
+	} {
+		assert.False(t, IsRetryable(err), fmt.Sprint(err))
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	calls := 0
+	err := testRetryPolicy.Do(context.Background(), nil, func() error {
+		calls++
+		if calls < 2 {
+			return &HTTPStatusError{StatusCode: http.StatusServiceUnavailable}
+		}
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, calls)
+
+	// Retries stop after the max attempts
+	calls = 0
+	err = testRetryPolicy.Do(context.Background(), nil, func() error {
+		calls++
+		return context.DeadlineExceeded
+	})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 3, calls)
+
+	// Permanent errors are not retried
+	calls = 0
+	err = testRetryPolicy.Do(context.Background(), nil, func() error {
+		calls++
+		return &HTTPStatusError{StatusCode: http.StatusNotFound}
+	})
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, calls)
+
+	// Nor are calls once the context is done
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	calls = 0
+	err = RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Hour}.Do(ctx, nil, func() error {
+		calls++
+		return context.DeadlineExceeded
+	})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 1, calls)
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}
+	assert.Equal(t, time.Second, policy.backoff(1, nil))
+	assert.Equal(t, 4*time.Second, policy.backoff(3, nil))
+	assert.Equal(t, 5*time.Second, policy.backoff(10, nil))
+	assert.Equal(t, 30*time.Second, policy.backoff(1, &HTTPStatusError{StatusCode: http.StatusTooManyRequests, RetryAfter: 30 * time.Second}))
+
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		backoff := policy.backoff(2, nil)
+		assert.True(t, backoff > time.Second && backoff <= 2*time.Second, backoff.String())
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	breaker := NewCircuitBreaker(CircuitBreakerPolicy{FailureThreshold: 2, OpenDuration: 20 * time.Millisecond})
+	failure := errors.New("metrics provider unavailable")
+	breaker.Record(failure)
+	require.Nil(t, breaker.Allow())
+	breaker.Record(failure)
+	assert.True(t, breaker.Open())
+	assert.ErrorIs(t, breaker.Allow(), ErrCircuitOpen)
+
+	// A single trial call is let through after the open duration
+	time.Sleep(20 * time.Millisecond)
+	require.Nil(t, breaker.Allow())
+	assert.ErrorIs(t, breaker.Allow(), ErrCircuitOpen)
+	breaker.Record(failure)
+	assert.ErrorIs(t, breaker.Allow(), ErrCircuitOpen)
+
+	time.Sleep(20 * time.Millisecond)
+	require.Nil(t, breaker.Allow())
+	breaker.Record(&PartialMetricsError{Err: failure})
+	assert.False(t, breaker.Open())
+	assert.Nil(t, breaker.Allow())
+
+	var nilBreaker *CircuitBreaker
+	assert.Nil(t, nilBreaker.Allow())
+}
+
+// Fails fetches with a retryable error until the given number of calls
+type flakyClient struct {
+	MetricsProviderClient
+	failures int32
+	calls    *int32
+}
+
+func (c flakyClient) FetchAllHostsMetrics(window *Window) (map[string][]Metric, error) {
+	if atomic.AddInt32(c.calls, 1) <= c.failures {
+		return nil, &HTTPStatusError{StatusCode: http.StatusServiceUnavailable}
+	}
+	return c.MetricsProviderClient.FetchAllHostsMetrics(window)
+}
+
+func TestWatcherRetriesFetches(t *testing.T) {
+	var calls int32
+	client := flakyClient{MetricsProviderClient: NewTestMetricsServerClient(), failures: 1, calls: &calls}
+	retryWatcher := NewWatcher(client, WatcherOpts{RetryPolicy: testRetryPolicy})
+	retryWatcher.isStarted = true
+	retryWatcher.fetchOnce(context.Background(), 15*time.Minute)
+
+	assert.Equal(t, int32(2), calls)
+	_, err := retryWatcher.GetLatestWatcherMetrics(FifteenMinutes)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, retryWatcher.WindowStatuses()[0].ConsecutiveFailures)
+
+	// The circuit opens after consecutive failures, and fetches are skipped
+	calls = 0
+	client.failures = 100
+	retryWatcher = NewWatcher(client, WatcherOpts{
+		RetryPolicy:          testRetryPolicy,
+		CircuitBreakerPolicy: CircuitBreakerPolicy{FailureThreshold: 2, OpenDuration: time.Hour},
+	})
+	retryWatcher.isStarted = true
+	retryWatcher.fetchOnce(context.Background(), 15*time.Minute)
+	assert.Equal(t, int32(2), calls)
+	retryWatcher.fetchOnce(context.Background(), 15*time.Minute)
+	assert.Equal(t, int32(2), calls)
+	status := retryWatcher.WindowStatuses()[0]
+	assert.Equal(t, 2, status.ConsecutiveFailures)
+	assert.ErrorIs(t, status.LastError, ErrCircuitOpen)
+}
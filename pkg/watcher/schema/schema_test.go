@@ -0,0 +1,199 @@
+/*
+Copyright 2020 PayPal
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schema_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/francoispqt/gojay"
+	"github.com/paypal/load-watcher/pkg/watcher"
+	"github.com/paypal/load-watcher/pkg/watcher/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Decodes watcher-example.json like clients of the watcher do. The example lists nodes directly under data rather
+// than under NodeMetricsMap, so only its other fields are decoded
+func readExample(t *testing.T) *watcher.WatcherMetrics {
+	example, err := os.ReadFile("watcher-example.json")
+	require.Nil(t, err)
+	metrics := &watcher.WatcherMetrics{Data: watcher.Data{NodeMetricsMap: make(watcher.NodeMetricsMap)}}
+	require.Nil(t, gojay.UnmarshalJSONObject(example, metrics))
+	require.Equal(t, int64(1556987522), metrics.Timestamp)
+	require.Equal(t, watcher.Window{Duration: "15m", Start: 1556984522, End: 1556985422}, metrics.Window)
+	require.Equal(t, "InfluxDB", metrics.Source)
+	return metrics
+}
+
+// Metrics of two nodes, one of them tagged and with all metadata
+func nodeMetrics() *watcher.WatcherMetrics {
+	metrics := []watcher.Metric{
+		{Name: "host.cpu.utilisation", Type: "cpu", Rollup: "AVG", Value: 20},
+		{Name: "host.memory.utilisation", Type: "memory", Rollup: "STD", Value: 5},
+	}
+	return &watcher.WatcherMetrics{
+		Timestamp: 1556987522,
+		Window:    watcher.Window{Duration: "15m", Start: 1556984522, End: 1556985422},
+		Source:    "InfluxDB",
+		Data: watcher.Data{NodeMetricsMap: watcher.NodeMetricsMap{
+			"node-1": {
+				Metrics: metrics,
+				Tags: watcher.Tags{
+					Labels: map[string]string{"team": "payments"},
+					Taints: []string{"dedicated=critical-apps:NoSchedule"},
+				},
+				Metadata: watcher.Metadata{
+					DataCenter:   "data-center-1",
+					Zone:         "us-east-1a",
+					Region:       "us-east-1",
+					InstanceType: "m5.xlarge",
+					NodePool:     "critical-apps",
+				},
+			},
+			"node-2": {
+				Metrics:  metrics,
+				Metadata: watcher.Metadata{DataCenter: "data-center-2", NodePool: "light-apps"},
+			},
+		}},
+	}
+}
+
+func TestProtobufRoundTrip(t *testing.T) {
+	withPods := nodeMetrics()
+	withPods.Stale = true
+	withPods.Data.PodMetricsMap = watcher.PodMetricsMap{
+		"default/app-1": {Namespace: "default", Name: "app-1", Node: "node-1", Metrics: []watcher.Metric{
+			{Name: "container_cpu_usage_seconds_total", Type: watcher.CPU, Operator: watcher.Latest, Value: 0.25},
+		}},
+	}
+	withPods.Data.NamespaceMetricsMap = watcher.NamespaceMetricsMap{
+		"default": {Metrics: []watcher.Metric{
+			{Name: "container_cpu_usage_seconds_total", Type: watcher.CPU, Operator: watcher.Latest, Value: 0.25},
+		}},
+	}
+
+	for _, metrics := range []*watcher.WatcherMetrics{readExample(t), nodeMetrics(), withPods} {
+		encoded, err := metrics.MarshalProtobuf()
+		require.Nil(t, err)
+		decoded := &watcher.WatcherMetrics{}
+		require.Nil(t, decoded.UnmarshalProtobuf(encoded))
+		assert.Equal(t, metrics, decoded)
+
+		// Both wire formats carry the same metrics
+		expectedJSON, err := gojay.MarshalJSONObject(metrics)
+		require.Nil(t, err)
+		decodedJSON, err := gojay.MarshalJSONObject(decoded)
+		require.Nil(t, err)
+		assert.JSONEq(t, string(expectedJSON), string(decodedJSON))
+		assert.Less(t, len(encoded), len(expectedJSON))
+	}
+}
+
+func TestProtobufUnmarshalInvalid(t *testing.T) {
+	decoded := &watcher.WatcherMetrics{}
+	assert.NotNil(t, decoded.UnmarshalProtobuf([]byte("{\"timestamp\": 1556987522}")))
+}
+
+// Every property of the JSON schema has a field of the same name in the protobuf schema, down to the metrics
+func TestProtobufCoversJSONSchema(t *testing.T) {
+	jsonSchema, err := os.ReadFile("watcher-schema.json")
+	require.Nil(t, err)
+	var root map[string]interface{}
+	require.Nil(t, json.Unmarshal(jsonSchema, &root))
+	assertCovers(t, "", root, (&schema.WatcherMetrics{}).ProtoReflect().Descriptor())
+}
+
+func assertCovers(t *testing.T, path string, object map[string]interface{}, message protoreflect.MessageDescriptor) {
+	properties, _ := object["properties"].(map[string]interface{})
+	patternProperties, _ := object["patternProperties"].(map[string]interface{})
+	require.False(t, len(properties) == 0 && len(patternProperties) == 0, "%s has no properties", path)
+	for name, property := range properties {
+		field := fieldByJSONName(message, name)
+		if !assert.NotNil(t, field, "%s.%s has no protobuf field in %s", path, name, message.FullName()) {
+			continue
+		}
+		assertCoversField(t, path+"."+name, property.(map[string]interface{}), field)
+	}
+	// Pattern properties are the entries of the map field not described by a property, e.g. the nodes of data
+	for pattern, property := range patternProperties {
+		field := unnamedMapField(message, properties)
+		if !assert.NotNil(t, field, "%s.%s has no protobuf map field in %s", path, pattern, message.FullName()) {
+			continue
+		}
+		assertCovers(t, path+"."+pattern, property.(map[string]interface{}), field.MapValue().Message())
+	}
+}
+
+func assertCoversField(t *testing.T, path string, property map[string]interface{}, field protoreflect.FieldDescriptor) {
+	switch {
+	case field.IsMap() && field.MapValue().Message() != nil:
+		assertCovers(t, path+".*", property["additionalProperties"].(map[string]interface{}), field.MapValue().Message())
+	case field.IsList() && field.Message() != nil:
+		// Items are described by a single schema, or by one per position
+		switch items := property["items"].(type) {
+		case map[string]interface{}:
+			assertCovers(t, path+"[]", items, field.Message())
+		case []interface{}:
+			for i, item := range items {
+				assertCovers(t, fmt.Sprintf("%s[%d]", path, i), item.(map[string]interface{}), field.Message())
+			}
+		default:
+			t.Errorf("%s has no items", path)
+		}
+	case field.Message() != nil && !field.IsMap():
+		assertCovers(t, path, property, field.Message())
+	}
+}
+
+func fieldByJSONName(message protoreflect.MessageDescriptor, name string) protoreflect.FieldDescriptor {
+	fields := message.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		if strings.EqualFold(fields.Get(i).JSONName(), name) {
+			return fields.Get(i)
+		}
+	}
+	return nil
+}
+
+// Returns the only map field of message with message values which is not one of properties, nil if there is none or several
+func unnamedMapField(message protoreflect.MessageDescriptor, properties map[string]interface{}) protoreflect.FieldDescriptor {
+	var unnamed protoreflect.FieldDescriptor
+	fields := message.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		if !field.IsMap() || field.MapValue().Message() == nil {
+			continue
+		}
+		named := false
+		for name := range properties {
+			named = named || strings.EqualFold(field.JSONName(), name)
+		}
+		if named {
+			continue
+		}
+		if unnamed != nil {
+			return nil
+		}
+		unnamed = field
+	}
+	return unnamed
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2020 PayPal
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+// NodeInfo Tags and metadata of a node, as returned by a NodeTagger
+type NodeInfo struct {
+	Tags     Tags
+	Metadata Metadata
+	// All labels of the node, matched by the label selector of queries. Unlike Tags, they are not served
+	Labels map[string]string
+}
+
+// Sets the tags, metadata and labels of the nodes known to the node tagger. Metadata set by the metrics provider
+// is kept where the node tagger has none
+func (w *Watcher) tagNodes(metrics *WatcherMetrics) {
+	for host, nodeMetrics := range metrics.Data.NodeMetricsMap {
+		info, ok := w.tagger.NodeInfo(host)
+		if !ok {
+			continue
+		}
+		nodeMetrics.Tags = info.Tags
+		nodeMetrics.Metadata = mergeMetadata(info.Metadata, nodeMetrics.Metadata)
+		nodeMetrics.labels = info.Labels
+		metrics.Data.NodeMetricsMap[host] = nodeMetrics
+	}
+}
+
+// Returns the metadata with its empty fields set from fallback
+func mergeMetadata(metadata Metadata, fallback Metadata) Metadata {
+	for _, field := range []struct{ value, fallback *string }{
+		{&metadata.DataCenter, &fallback.DataCenter},
+		{&metadata.Zone, &fallback.Zone},
+		{&metadata.Region, &fallback.Region},
+		{&metadata.InstanceType, &fallback.InstanceType},
+		{&metadata.NodePool, &fallback.NodePool},
+	} {
+		if *field.value == "" {
+			*field.value = *field.fallback
+		}
+	}
+	return metadata
+}
+
+func (t Tags) deepCopy() Tags {
+	tags := Tags{Taints: append([]string(nil), t.Taints...)}
+	if t.Labels != nil {
+		tags.Labels = make(map[string]string, len(t.Labels))
+		for key, value := range t.Labels {
+			tags.Labels[key] = value
+		}
+	}
+	return tags
+}
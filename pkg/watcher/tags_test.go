@@ -0,0 +1,101 @@
+/*
+Copyright 2020 PayPal
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/francoispqt/gojay"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Tags FirstNode and SecondNode as nodes of two zones
+type testNodeTagger struct{}
+
+func (testNodeTagger) NodeInfo(host string) (NodeInfo, bool) {
+	zones := map[string]string{FirstNode: "zone-a", SecondNode: "zone-b"}
+	zone, ok := zones[host]
+	if !ok {
+		return NodeInfo{}, false
+	}
+	return NodeInfo{
+		Tags: Tags{
+			Labels: map[string]string{"team": "web"},
+			Taints: []string{"dedicated=web:NoSchedule"},
+		},
+		Metadata: Metadata{Zone: zone, Region: "region-1", InstanceType: "m5.xlarge", NodePool: "pool-1"},
+		Labels:   map[string]string{"topology.kubernetes.io/zone": zone, "team": "web", "kubernetes.io/hostname": host},
+	}, true
+}
+
+func TestWatcherNodeTags(t *testing.T) {
+	taggedWatcher := NewWatcher(NewTestMetricsServerClient(), WatcherOpts{NodeTagger: testNodeTagger{}})
+	taggedWatcher.isStarted = true
+	taggedWatcher.fetchOnce(context.Background(), 15*time.Minute)
+
+	rr := servePods(t, taggedWatcher, BaseUrl)
+	require.Equal(t, http.StatusOK, rr.Code)
+	metrics := &WatcherMetrics{Data: Data{NodeMetricsMap: make(NodeMetricsMap)}}
+	require.Nil(t, gojay.UnmarshalJSONObject(rr.Body.Bytes(), metrics))
+	first := metrics.Data.NodeMetricsMap[FirstNode]
+	assert.Equal(t, Tags{
+		Labels: map[string]string{"team": "web"},
+		Taints: []string{"dedicated=web:NoSchedule"},
+	}, first.Tags)
+	assert.Equal(t, Metadata{Zone: "zone-a", Region: "region-1", InstanceType: "m5.xlarge", NodePool: "pool-1"}, first.Metadata)
+	assert.NotContains(t, rr.Body.String(), "kubernetes.io/hostname")
+
+	// Labels which are not tags can be selected too
+	rr = servePods(t, taggedWatcher, BaseUrl+"?labelSelector=topology.kubernetes.io/zone%3Dzone-b")
+	require.Equal(t, http.StatusOK, rr.Code)
+	metrics = &WatcherMetrics{Data: Data{NodeMetricsMap: make(NodeMetricsMap)}}
+	require.Nil(t, gojay.UnmarshalJSONObject(rr.Body.Bytes(), metrics))
+	assert.Len(t, metrics.Data.NodeMetricsMap, 1)
+	assert.Contains(t, metrics.Data.NodeMetricsMap, SecondNode)
+
+	assert.Equal(t, http.StatusNotFound, servePods(t, taggedWatcher, BaseUrl+"?labelSelector=team%3Ddb").Code)
+	assert.Equal(t, http.StatusBadRequest, servePods(t, taggedWatcher, BaseUrl+"?labelSelector=team+in+db").Code)
+
+	// Copies of cached metrics do not share tags
+	latest, err := taggedWatcher.GetLatestWatcherMetrics(FifteenMinutes)
+	require.Nil(t, err)
+	latest.Data.NodeMetricsMap[FirstNode].Tags.Labels["team"] = "db"
+	latest, err = taggedWatcher.GetLatestWatcherMetrics(FifteenMinutes)
+	require.Nil(t, err)
+	assert.Equal(t, "web", latest.Data.NodeMetricsMap[FirstNode].Tags.Labels["team"])
+}
+
+func TestMetricsQueryFilterTagLabels(t *testing.T) {
+	// Without the labels of the node, e.g. for metrics received from a Watcher service, tag labels are matched
+	metrics := &WatcherMetrics{Data: Data{NodeMetricsMap: NodeMetricsMap{
+		FirstNode:  {Tags: Tags{Labels: map[string]string{"team": "web"}}},
+		SecondNode: {},
+	}}}
+	filtered, err := MetricsQuery{LabelSelector: "team=web"}.Filter(metrics)
+	require.Nil(t, err)
+	assert.Len(t, filtered.Data.NodeMetricsMap, 1)
+	assert.Contains(t, filtered.Data.NodeMetricsMap, FirstNode)
+
+	filtered, err = MetricsQuery{LabelSelector: "!team"}.Filter(metrics)
+	require.Nil(t, err)
+	assert.Len(t, filtered.Data.NodeMetricsMap, 1)
+	assert.Contains(t, filtered.Data.NodeMetricsMap, SecondNode)
+}
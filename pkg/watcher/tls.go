@@ -0,0 +1,159 @@
+/*
+Copyright 2020 PayPal
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultCertReloadInterval = 30 * time.Second
+)
+
+// Serves the Watcher TLS certificate and client CAs, reloading them when the files on disk change
+type certReloader struct {
+	certFile     string
+	keyFile      string
+	clientCAFile string // Optional, enables mTLS when set
+
+	mutex     sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	modTimes  map[string]time.Time // Modification time of each file when last loaded
+}
+
+func newCertReloader(certFile string, keyFile string, clientCAFile string) (*certReloader, error) {
+	if certFile == "" || keyFile == "" {
+		return nil, errors.New("both TLS cert and key files are required")
+	}
+	r := &certReloader{
+		certFile:     certFile,
+		keyFile:      keyFile,
+		clientCAFile: clientCAFile,
+		modTimes:     make(map[string]time.Time),
+	}
+	if _, err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reloads the certificate and client CAs if any of the files changed since they were last loaded.
+// Returns true if they were reloaded. The previously loaded files are kept on error.
+func (r *certReloader) reload() (bool, error) {
+	files := []string{r.certFile, r.keyFile}
+	if r.clientCAFile != "" {
+		files = append(files, r.clientCAFile)
+	}
+	modTimes := make(map[string]time.Time)
+	changed := false
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return false, err
+		}
+		modTimes[file] = info.ModTime()
+		r.mutex.RLock()
+		if !info.ModTime().Equal(r.modTimes[file]) {
+			changed = true
+		}
+		r.mutex.RUnlock()
+	}
+	if !changed {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return false, fmt.Errorf("unable to load TLS key pair: %v", err)
+	}
+	var clientCAs *x509.CertPool
+	if r.clientCAFile != "" {
+		caCert, err := os.ReadFile(r.clientCAFile)
+		if err != nil {
+			return false, err
+		}
+		clientCAs = x509.NewCertPool()
+		if ok := clientCAs.AppendCertsFromPEM(caCert); !ok {
+			return false, fmt.Errorf("failed to append client CA certificate to the pool")
+		}
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.cert = &cert
+	r.clientCAs = clientCAs
+	r.modTimes = modTimes
+	return true, nil
+}
+
+// Polls the files for changes until ctx is done
+func (r *certReloader) watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		reloaded, err := r.reload()
+		if err != nil {
+			log.Errorf("unable to reload TLS certificates, serving previous ones: %v", err)
+			continue
+		}
+		if reloaded {
+			log.Info("reloaded TLS certificates")
+		}
+	}
+}
+
+func (r *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.cert, nil
+}
+
+// Returns the server TLS config, which always serves the latest loaded files
+func (r *certReloader) tlsConfig() *tls.Config {
+	config := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: r.getCertificate,
+	}
+	if r.clientCAFile != "" {
+		config.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			r.mutex.RLock()
+			defer r.mutex.RUnlock()
+			return &tls.Config{
+				MinVersion:     tls.VersionTLS12,
+				GetCertificate: r.getCertificate,
+				ClientCAs:      r.clientCAs,
+				ClientAuth:     tls.RequireAndVerifyClientCert,
+			}, nil
+		}
+	}
+	return config
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2020 PayPal
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Creates a certificate signed by parent, or self-signed if parent is nil
+func newTestCert(t *testing.T, serial int64, isCA bool, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey, []byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: "load-watcher"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	require.Nil(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.Nil(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.Nil(t, err)
+	return cert, key, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+}
+
+func TestCertReloaderMTLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	caFile := filepath.Join(dir, "ca.crt")
+
+	ca, caKey, caPEM, _ := newTestCert(t, 1, true, nil, nil)
+	_, _, serverPEM, serverKeyPEM := newTestCert(t, 2, false, ca, caKey)
+	_, _, clientPEM, clientKeyPEM := newTestCert(t, 3, false, ca, caKey)
+	require.Nil(t, os.WriteFile(caFile, caPEM, 0600))
+	require.Nil(t, os.WriteFile(certFile, serverPEM, 0600))
+	require.Nil(t, os.WriteFile(keyFile, serverKeyPEM, 0600))
+
+	reloader, err := newCertReloader(certFile, keyFile, caFile)
+	require.Nil(t, err)
+	server := httptest.NewUnstartedServer(w.Handler())
+	server.TLS = reloader.tlsConfig()
+	server.StartTLS()
+	defer server.Close()
+
+	rootCAs := x509.NewCertPool()
+	rootCAs.AppendCertsFromPEM(caPEM)
+	clientCert, err := tls.X509KeyPair(clientPEM, clientKeyPEM)
+	require.Nil(t, err)
+	get := func(certificates []tls.Certificate) (*http.Response, error) {
+		client := http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: rootCAs, Certificates: certificates},
+		}}
+		return client.Get(server.URL + BaseUrl)
+	}
+
+	// Client certificates are required
+	_, err = get(nil)
+	assert.NotNil(t, err)
+	resp, err := get([]tls.Certificate{clientCert})
+	require.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int64(2), resp.TLS.PeerCertificates[0].SerialNumber.Int64())
+
+	// Unchanged files are not reloaded
+	reloaded, err := reloader.reload()
+	require.Nil(t, err)
+	assert.False(t, reloaded)
+
+	// Rotated certificates are served to new connections
+	_, _, rotatedPEM, rotatedKeyPEM := newTestCert(t, 4, false, ca, caKey)
+	require.Nil(t, os.WriteFile(certFile, rotatedPEM, 0600))
+	require.Nil(t, os.WriteFile(keyFile, rotatedKeyPEM, 0600))
+	future := time.Now().Add(time.Minute)
+	require.Nil(t, os.Chtimes(certFile, future, future))
+	require.Nil(t, os.Chtimes(keyFile, future, future))
+	reloaded, err = reloader.reload()
+	require.Nil(t, err)
+	assert.True(t, reloaded)
+
+	resp, err = get([]tls.Certificate{clientCert})
+	require.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, int64(4), resp.TLS.PeerCertificates[0].SerialNumber.Int64())
+}
+
+func TestCertReloaderMissingFiles(t *testing.T) {
+	_, err := newCertReloader("", "", "")
+	assert.NotNil(t, err)
+	_, err = newCertReloader("/nonexistent/tls.crt", "/nonexistent/tls.key", "")
+	assert.NotNil(t, err)
+}
@@ -25,9 +25,14 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
@@ -39,6 +44,7 @@ import (
 const (
 	BaseUrl         = "/watcher"
 	HealthCheckUrl  = "/watcher/health"
+	HistoryUrl      = "/watcher/history"
 	FifteenMinutes  = "15m"
 	TenMinutes      = "10m"
 	FiveMinutes     = "5m"
@@ -50,19 +56,109 @@ const (
 	Unknown         = "Unknown"
 	Average         = "AVG"
 	Std             = "STD"
+	Max             = "MAX"
+	Min             = "MIN"
+	P50             = "P50"
+	P90             = "P90"
+	P95             = "P95"
+	P99             = "P99"
 	Latest          = "Latest"
 	UnknownOperator = "Unknown"
 )
 
+var (
+	// DefaultWindows are the windows watched when none are configured
+	DefaultWindows = []time.Duration{15 * time.Minute, 10 * time.Minute, 5 * time.Minute}
+	// ErrStaleMetrics is returned along with the latest metrics when all of them are older than the max age
+	ErrStaleMetrics = errors.New("only stale metrics are available")
+)
+
+const (
+	DefaultAddress        = ":2020"
+	DefaultCacheSize      = 5
+	DefaultMaxAge         = 10 * time.Minute
+	defaultFetchInterval  = time.Minute
+	serverShutdownTimeout = time.Minute
+)
+
 type Watcher struct {
-	mutex         sync.RWMutex // For thread safe access to cache
-	fifteenMinute []WatcherMetrics
-	tenMinute     []WatcherMetrics
-	fiveMinute    []WatcherMetrics
-	cacheSize     int
-	client        MetricsProviderClient
-	isStarted     bool // Indicates if the Watcher is started by calling StartWatching()
-	shutdown      chan os.Signal
+	mutex          sync.RWMutex // For thread safe access to cache
+	lifecycleMutex sync.Mutex   // Serialises Start() and Stop()
+	windows        map[time.Duration]*windowCache
+	durations      []time.Duration // Registered window durations, largest first
+	cacheSize      int
+	maxAge         time.Duration // Age after which metrics are stale, staleness is not enforced if not positive
+	fetchInterval  time.Duration
+	client         ContextMetricsProviderClient
+	podClient      ContextPodMetricsProviderClient // nil if the metrics provider does not support pod metrics
+	tagger         NodeTagger                      // nil if nodes are not tagged
+	sourceClient   SourceProviderClient            // nil if the metrics provider has a single backend
+	retryPolicy    RetryPolicy                     // Retries failed fetches within a fetch interval
+	breaker        *CircuitBreaker                 // Stops fetches from a failing metrics provider
+	isStarted      bool                            // Indicates if the Watcher is started by calling Start()
+	handleSignals  bool
+	mux            *http.ServeMux
+	instruments    *instruments
+	serverOpts     WatcherOpts
+	certReload     time.Duration // Interval to check TLS files for changes
+	shutdown       chan os.Signal
+	cancel         context.CancelFunc // Cancels the context of a started Watcher
+	done           chan struct{}      // Closed once a started Watcher has stopped
+	wg             sync.WaitGroup     // Tracks goroutines of a started Watcher
+}
+
+// Cache of recent metrics fetched for a single window duration
+type windowCache struct {
+	duration            time.Duration
+	metrics             []WatcherMetrics
+	lastSuccess         time.Time // Time of the last successful fetch
+	lastFailure         time.Time // Time of the last failed fetch
+	lastError           error     // Error of the last failed fetch
+	consecutiveFailures int       // Number of failed fetches since the last successful one
+}
+
+// WindowStatus Reports the fetch status of a window
+type WindowStatus struct {
+	Window              string
+	LastSuccess         time.Time
+	LastFailure         time.Time
+	LastError           error
+	ConsecutiveFailures int
+	// Number of metrics cached
+	CacheSize int
+	// Timestamp of the latest cached metrics, zero if none are cached
+	LatestTimestamp int64
+	// The latest cached metrics are older than the max age
+	Stale bool
+}
+
+// Watcher options
+type WatcherOpts struct {
+	// Window durations to watch, DefaultWindows if empty
+	Windows []time.Duration
+	// Number of most recent metrics kept per window, DefaultCacheSize if not positive
+	CacheSize int
+	// Age after which cached metrics are stale, DefaultMaxAge if zero. Staleness is not enforced if negative
+	MaxAge time.Duration
+	// Stop the Watcher on SIGINT and SIGTERM. Applications which own signal handling should leave this unset,
+	// and call Stop() or cancel the context passed to Start() instead
+	HandleSignals bool
+	// Address the server listens on, DefaultAddress if empty
+	Address string
+	// Do not start a server. Applications can mount Handler() on their own server instead
+	DisableServer bool
+	// Serve HTTPS with this certificate and key. Both files are reloaded when they change on disk
+	TLSCertFile string
+	TLSKeyFile  string
+	// Require client certificates signed by this CA (mTLS). Only used along with TLSCertFile and TLSKeyFile
+	TLSClientCAFile string
+	// Tags fetched nodes with their labels, taints and metadata, such as zone and instance type, if set
+	NodeTagger NodeTagger
+	// Retries failed fetches from the metrics provider, DefaultRetryPolicy if MaxAttempts is zero
+	RetryPolicy RetryPolicy
+	// Stops fetches from the metrics provider after consecutive failures, DefaultCircuitBreakerPolicy if FailureThreshold
+	// is zero. The circuit never opens if FailureThreshold is negative
+	CircuitBreakerPolicy CircuitBreakerPolicy
 }
 
 type Window struct {
@@ -81,8 +177,17 @@ type Metric struct {
 
 type NodeMetricsMap map[string]NodeMetrics
 
+// PodMetricsMap Pod metrics keyed by namespace/name
+type PodMetricsMap map[string]PodMetrics
+
+// NamespaceMetricsMap Namespace metrics keyed by namespace
+type NamespaceMetricsMap map[string]NamespaceMetrics
+
 type Data struct {
 	NodeMetricsMap NodeMetricsMap
+	// Only present for metrics providers supporting pod metrics
+	PodMetricsMap       PodMetricsMap       `json:",omitempty"`
+	NamespaceMetricsMap NamespaceMetricsMap `json:",omitempty"`
 }
 
 type WatcherMetrics struct {
@@ -90,149 +195,516 @@ type WatcherMetrics struct {
 	Window    Window `json:"window"`
 	Source    string `json:"source"`
 	Data      Data   `json:"data"`
+	Stale     bool   `json:"stale,omitempty"` // Metrics are older than the Watcher max age
 }
 
+// Tags Labels and taints of a node, as selected by the node tagger
 type Tags struct {
+	Labels map[string]string `json:"labels,omitempty"`
+	Taints []string          `json:"taints,omitempty"` // Formatted as key=value:Effect
 }
 
 type Metadata struct {
-	DataCenter string `json:"dataCenter,omitempty"`
+	DataCenter   string `json:"dataCenter,omitempty"`
+	Zone         string `json:"zone,omitempty"`
+	Region       string `json:"region,omitempty"`
+	InstanceType string `json:"instanceType,omitempty"`
+	NodePool     string `json:"pool,omitempty"`
 }
 
 type NodeMetrics struct {
 	Metrics  []Metric `json:"metrics,omitempty"`
 	Tags     Tags     `json:"tags,omitempty"`
 	Metadata Metadata `json:"metadata,omitempty"`
+	// All labels of the node, matched by label selectors. Only known to the Watcher which tagged the node
+	labels map[string]string
 }
 
-// NewWatcher Returns a new initialised Watcher
-func NewWatcher(client MetricsProviderClient) *Watcher {
-	sizePerWindow := 5
-	return &Watcher{
+// PodMetrics Metrics of a pod. Unlike node metrics, values are absolute: CPU in cores and Memory in bytes
+type PodMetrics struct {
+	Namespace string   `json:"namespace"`
+	Name      string   `json:"name"`
+	Node      string   `json:"node,omitempty"` // Node the pod runs on, if known
+	Metrics   []Metric `json:"metrics,omitempty"`
+}
+
+// NamespaceMetrics Metrics of all pods of a namespace, summed up. Only additive operators, AVG and Latest, are summed up
+type NamespaceMetrics struct {
+	Metrics []Metric `json:"metrics,omitempty"`
+}
+
+// NewWatcher Returns a new initialised Watcher, watching all windows in opts
+func NewWatcher(client MetricsProviderClient, opts WatcherOpts) *Watcher {
+	sizePerWindow := DefaultCacheSize
+	if opts.CacheSize > 0 {
+		sizePerWindow = opts.CacheSize
+	}
+	durations := opts.Windows
+	if len(durations) == 0 {
+		durations = DefaultWindows
+	}
+
+	w := &Watcher{
 		mutex:         sync.RWMutex{},
-		fifteenMinute: make([]WatcherMetrics, 0, sizePerWindow),
-		tenMinute:     make([]WatcherMetrics, 0, sizePerWindow),
-		fiveMinute:    make([]WatcherMetrics, 0, sizePerWindow),
+		windows:       make(map[time.Duration]*windowCache),
 		cacheSize:     sizePerWindow,
-		client:        client,
+		maxAge:        opts.MaxAge,
+		fetchInterval: defaultFetchInterval,
+		tagger:        opts.NodeTagger,
+		handleSignals: opts.HandleSignals,
+		serverOpts:    opts,
+		certReload:    defaultCertReloadInterval,
 		shutdown:      make(chan os.Signal, 1),
 	}
+	if w.maxAge == 0 {
+		w.maxAge = DefaultMaxAge
+	}
+	if w.serverOpts.Address == "" {
+		w.serverOpts.Address = DefaultAddress
+	}
+	w.retryPolicy = opts.RetryPolicy
+	if w.retryPolicy.MaxAttempts == 0 {
+		w.retryPolicy = DefaultRetryPolicy
+	}
+	breakerPolicy := opts.CircuitBreakerPolicy
+	if breakerPolicy.FailureThreshold == 0 {
+		breakerPolicy = DefaultCircuitBreakerPolicy
+	}
+	w.breaker = NewCircuitBreaker(breakerPolicy)
+	w.instruments = newInstruments(w)
+	adapter := WithContext(client)
+	w.client = instrumentedClient{client: adapter, instruments: w.instruments}
+	if adapter.SupportsPodMetrics() {
+		w.podClient = instrumentedClient{client: adapter, instruments: w.instruments}
+	}
+	if sourceClient, ok := client.(SourceProviderClient); ok {
+		w.sourceClient = sourceClient
+	}
+	w.mux = http.NewServeMux()
+	w.mux.Handle(BaseUrl, w.instruments.instrumentHandler(BaseUrl, w.handler))
+	w.mux.Handle(HealthCheckUrl, w.instruments.instrumentHandler(HealthCheckUrl, w.healthCheckHandler))
+	w.mux.Handle(HistoryUrl, w.instruments.instrumentHandler(HistoryUrl, w.historyHandler))
+	w.mux.Handle(PodsUrl, w.instruments.instrumentHandler(PodsUrl, w.podsHandler))
+	w.mux.Handle(LivenessUrl, w.instruments.instrumentHandler(LivenessUrl, w.livenessHandler))
+	w.mux.Handle(ReadinessUrl, w.instruments.instrumentHandler(ReadinessUrl, w.readinessHandler))
+	w.mux.Handle(PrometheusUrl, w.instruments.instrumentHandler(PrometheusUrl, w.prometheusHandler))
+	w.mux.Handle(MetricsUrl, w.instruments.handler())
+
+	for _, duration := range durations {
+		if duration <= 0 {
+			log.Warnf("ignoring invalid window duration %v", duration)
+			continue
+		}
+		if _, ok := w.windows[duration]; ok {
+			continue
+		}
+		w.windows[duration] = &windowCache{
+			duration: duration,
+			metrics:  make([]WatcherMetrics, 0, sizePerWindow),
+		}
+		w.durations = append(w.durations, duration)
+	}
+	sort.Slice(w.durations, func(i, j int) bool {
+		return w.durations[i] > w.durations[j]
+	})
+	return w
 }
 
 // StartWatching This function needs to be called to begin actual watching
+//
+// Deprecated: use Start, which lets the caller stop the Watcher.
 func (w *Watcher) StartWatching() {
-	w.mutex.RLock()
-	if w.isStarted {
-		w.mutex.RUnlock()
-		return
+	if err := w.Start(context.Background()); err != nil {
+		log.Errorf("unable to start watching metrics: %v", err)
 	}
-	w.mutex.RUnlock()
+}
 
-	fetchOnce := func(duration string) {
-		curWindow, metric := w.getCurrentWindow(duration)
-		hostMetrics, err := w.client.FetchAllHostsMetrics(curWindow)
+// Start Begins watching metrics, populating the cache for every window before returning, and starts the server.
+// Watching continues until ctx is done or Stop() is called, after which the Watcher may be started again.
+// Calling Start on a started Watcher is a no-op.
+func (w *Watcher) Start(ctx context.Context) error {
+	w.lifecycleMutex.Lock()
+	defer w.lifecycleMutex.Unlock()
+	if w.done != nil {
+		select {
+		case <-w.done:
+			// Stopped by the end of ctx or a signal
+			w.done = nil
+			w.cancel = nil
+		default:
+			return nil
+		}
+	}
+	ctx, cancel := context.WithCancel(ctx)
 
-		if err != nil {
-			log.Errorf("received error while fetching metrics: %v", err)
-			return
+	for _, duration := range w.durations {
+		// Populate cache initially before returning
+		w.fetchOnce(ctx, duration)
+		w.wg.Add(1)
+		go w.windowWatcher(ctx, duration)
+	}
+
+	var server *http.Server
+	if !w.serverOpts.DisableServer {
+		var err error
+		if server, err = w.startServer(ctx); err != nil {
+			cancel()
+			w.wg.Wait()
+			return err
 		}
-		log.Debugf("fetched metrics for window: %v", curWindow)
+	}
 
-		watcherMetrics := metricMapToWatcherMetrics(hostMetrics, w.client.Name(), *curWindow)
-		w.appendWatcherMetrics(metric, &watcherMetrics)
+	if w.handleSignals {
+		signal.Notify(w.shutdown, os.Interrupt, syscall.SIGTERM)
 	}
 
-	windowWatcher := func(duration string) {
-		for {
-			fetchOnce(duration)
-			// This is assuming fetching of metrics won't exceed more than 1 minute. If it happens we need to throttle rate of fetches
-			time.Sleep(time.Minute)
+	w.cancel = cancel
+	w.done = make(chan struct{})
+	w.mutex.Lock()
+	w.isStarted = true
+	w.mutex.Unlock()
+
+	go w.stopWhenDone(ctx, cancel, server, w.done)
+	log.Info("Started watching metrics")
+	return nil
+}
+
+// Waits until ctx is done or a signal is received, then shuts down the server and marks the Watcher as stopped
+// once all its goroutines have exited
+func (w *Watcher) stopWhenDone(ctx context.Context, cancel context.CancelFunc, server *http.Server, done chan struct{}) {
+	defer close(done)
+	select {
+	case <-ctx.Done():
+	case <-w.shutdown:
+		cancel()
+	}
+	signal.Stop(w.shutdown)
+	if server != nil {
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), serverShutdownTimeout)
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Errorf("Unable to shutdown server: %v", err)
 		}
+		shutdownCancel()
 	}
+	w.wg.Wait()
 
-	durations := [3]string{FifteenMinutes, TenMinutes, FiveMinutes}
-	for _, duration := range durations {
-		// Populate cache initially before returning
-		fetchOnce(duration)
-		go windowWatcher(duration)
+	w.mutex.Lock()
+	w.isStarted = false
+	w.mutex.Unlock()
+	log.Info("Stopped watching metrics")
+}
+
+// Stop Stops watching metrics and shuts down the server, returning once all goroutines of the Watcher have exited.
+// Cached metrics are no longer served after Stop returns. The Watcher may be started again.
+func (w *Watcher) Stop() {
+	w.lifecycleMutex.Lock()
+	defer w.lifecycleMutex.Unlock()
+	if w.done == nil {
+		return
 	}
+	w.cancel()
+	<-w.done
+	w.cancel = nil
+	w.done = nil
+}
+
+// Handler Returns the HTTP handler serving the Watcher endpoints, for applications mounting it on their own server
+func (w *Watcher) Handler() http.Handler {
+	return w.mux
+}
 
-	http.HandleFunc(BaseUrl, w.handler)
-	http.HandleFunc(HealthCheckUrl, w.healthCheckHandler)
+// Listens on the configured address, and serves HTTPS if TLS files are configured
+func (w *Watcher) startServer(ctx context.Context) (*http.Server, error) {
 	server := &http.Server{
-		Addr:    ":2020",
-		Handler: http.DefaultServeMux,
+		Addr:    w.serverOpts.Address,
+		Handler: w.mux,
+	}
+	var reloader *certReloader
+	if w.serverOpts.TLSCertFile != "" || w.serverOpts.TLSKeyFile != "" {
+		var err error
+		reloader, err = newCertReloader(w.serverOpts.TLSCertFile, w.serverOpts.TLSKeyFile, w.serverOpts.TLSClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		server.TLSConfig = reloader.tlsConfig()
+	}
+
+	listener, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		return nil, err
 	}
 
+	w.wg.Add(1)
 	go func() {
-		log.Warn(server.ListenAndServe())
+		defer w.wg.Done()
+		var err error
+		if reloader != nil {
+			err = server.ServeTLS(listener, "", "")
+		} else {
+			err = server.Serve(listener)
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Warn(err)
+		}
 	}()
+	if reloader != nil {
+		w.wg.Add(1)
+		go func() {
+			defer w.wg.Done()
+			reloader.watch(ctx, w.certReload)
+		}()
+	}
+	log.Infof("Serving watcher endpoints on %v", listener.Addr())
+	return server, nil
+}
 
-	signal.Notify(w.shutdown, os.Interrupt, syscall.SIGTERM)
+// Fetches and caches the metrics of a window. Fetches are canceled when ctx is done, and must complete within the fetch interval
+func (w *Watcher) fetchOnce(ctx context.Context, duration time.Duration) {
+	ctx, cancel := context.WithTimeout(ctx, w.fetchInterval)
+	defer cancel()
+	curWindow := CurrentWindow(duration)
+	var hostMetrics map[string][]Metric
+	err := w.retryPolicy.Do(ctx, w.breaker, func() error {
+		start := time.Now()
+		var err error
+		hostMetrics, err = w.client.FetchAllHostsMetricsContext(ctx, curWindow)
+		w.instruments.observeFetch(w.client.Name(), curWindow.Duration, start, len(hostMetrics), err)
+		if IsRetryable(err) {
+			log.Warnf("retryable error while fetching metrics: %v", err)
+		}
+		return err
+	})
+
+	var partialErr *PartialMetricsError
+	if errors.As(err, &partialErr) && len(hostMetrics) > 0 {
+		log.Warnf("caching partial metrics of %v hosts: %v", len(hostMetrics), err)
+	} else if err != nil && errors.Is(err, context.Canceled) {
+		// The Watcher is stopping
+		return
+	} else if err != nil {
+		log.Errorf("received error while fetching metrics: %v", err)
+		w.recordFetchFailure(duration, err)
+		return
+	}
+	log.Debugf("fetched metrics for window: %v", curWindow)
 
-	go func() {
-		<-w.shutdown
-		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
-		defer cancel()
-		if err := server.Shutdown(ctx); err != nil {
-			log.Errorf("Unable to shutdown server: %v", err)
+	source := w.client.Name()
+	if w.sourceClient != nil {
+		if fetchSource := w.sourceClient.FetchSource(curWindow); fetchSource != "" {
+			source = fetchSource
 		}
-	}()
+	}
+	watcherMetrics := metricMapToWatcherMetrics(hostMetrics, source, *curWindow)
+	if w.tagger != nil {
+		w.tagNodes(&watcherMetrics)
+	}
+	if w.podClient != nil {
+		// Pod metrics are optional, so node metrics are cached even if fetching pod metrics failed
+		podMetrics, err := w.podClient.FetchAllPodsMetricsContext(ctx, curWindow)
+		if err != nil {
+			log.Errorf("received error while fetching pod metrics: %v", err)
+		}
+		if len(podMetrics) > 0 {
+			watcherMetrics.Data.PodMetricsMap = podMetrics
+			watcherMetrics.Data.NamespaceMetricsMap = sumNamespaceMetrics(podMetrics)
+		}
+	}
+	w.appendWatcherMetrics(duration, &watcherMetrics)
+}
 
-	w.mutex.Lock()
-	w.isStarted = true
-	w.mutex.Unlock()
-	log.Info("Started watching metrics")
+func (w *Watcher) windowWatcher(ctx context.Context, duration time.Duration) {
+	defer w.wg.Done()
+	timer := time.NewTimer(w.fetchInterval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+		w.fetchOnce(ctx, duration)
+		timer.Reset(w.fetchInterval)
+	}
 }
 
-// GetLatestWatcherMetrics It starts from 15 minute window, and falls back to 10 min, 5 min windows subsequently
-// if metrics are not present. StartWatching() should be called before calling this.
+// GetLatestWatcherMetrics It starts from the given window, and falls back to the smaller registered windows
+// subsequently if metrics are not present. Start() should be called before calling this.
 func (w *Watcher) GetLatestWatcherMetrics(duration string) (*WatcherMetrics, error) {
 	w.mutex.RLock()
 	defer w.mutex.RUnlock()
 	if !w.isStarted {
-		return nil, errors.New("need to call StartWatching() first")
-	}
-
-	switch {
-	case duration == FifteenMinutes && len(w.fifteenMinute) > 0:
-		return w.deepCopyWatcherMetrics(&w.fifteenMinute[len(w.fifteenMinute)-1]), nil
-	case (duration == FifteenMinutes || duration == TenMinutes) && len(w.tenMinute) > 0:
-		return w.deepCopyWatcherMetrics(&w.tenMinute[len(w.tenMinute)-1]), nil
-	case (duration == TenMinutes || duration == FiveMinutes) && len(w.fiveMinute) > 0:
-		return w.deepCopyWatcherMetrics(&w.fiveMinute[len(w.fiveMinute)-1]), nil
-	default:
-		return nil, errors.New("unable to get any latest metrics")
-	}
-}
-
-func (w *Watcher) getCurrentWindow(duration string) (*Window, *[]WatcherMetrics) {
-	var curWindow *Window
-	var watcherMetrics *[]WatcherMetrics
-	switch duration {
-	case FifteenMinutes:
-		curWindow = CurrentFifteenMinuteWindow()
-		watcherMetrics = &w.fifteenMinute
-	case TenMinutes:
-		curWindow = CurrentTenMinuteWindow()
-		watcherMetrics = &w.tenMinute
-	case FiveMinutes:
-		curWindow = CurrentFiveMinuteWindow()
-		watcherMetrics = &w.fiveMinute
-	default:
-		log.Error("received unexpected window duration, defaulting to 15m")
-		curWindow = CurrentFifteenMinuteWindow()
-	}
-	return curWindow, watcherMetrics
-}
-
-func (w *Watcher) appendWatcherMetrics(recentMetrics *[]WatcherMetrics, metric *WatcherMetrics) {
+		return nil, errors.New("need to call Start() first")
+	}
+
+	requested, err := ParseWindowDuration(duration)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := w.windows[requested]; !ok {
+		return nil, errNotWatched(duration)
+	}
+
+	// Prefer fresh metrics of any window in the fallback chain over stale ones
+	var stale *WatcherMetrics
+	for _, d := range w.fallbackDurations(requested) {
+		cache := w.windows[d]
+		if len(cache.metrics) == 0 {
+			continue
+		}
+		latest := &cache.metrics[len(cache.metrics)-1]
+		if !w.isStale(latest) {
+			return w.deepCopyWatcherMetrics(latest), nil
+		}
+		if stale == nil {
+			stale = latest
+		}
+	}
+	if stale != nil {
+		metrics := w.deepCopyWatcherMetrics(stale)
+		metrics.Stale = true
+		return metrics, ErrStaleMetrics
+	}
+	return nil, errors.New("unable to get any latest metrics")
+}
+
+// GetWatcherMetrics Returns the latest metrics of the query window, falling back like GetLatestWatcherMetrics,
+// with only the hosts and metrics selected by the query. The default window is used if the query has none
+func (w *Watcher) GetWatcherMetrics(query MetricsQuery) (*WatcherMetrics, error) {
+	window := query.Window
+	if window == "" {
+		window = w.defaultWindow()
+	}
+	metrics, err := w.GetLatestWatcherMetrics(window)
+	if metrics == nil {
+		return nil, err
+	}
+	filtered, filterErr := query.Filter(metrics)
+	if filterErr != nil {
+		return nil, filterErr
+	}
+	filtered.Stale = metrics.Stale
+	return filtered, err
+}
+
+// GetWatcherMetricsHistory Returns all cached metrics of the given window fetched at or after since (unix seconds),
+// oldest first. There is no fallback to other windows. Start() should be called before calling this.
+func (w *Watcher) GetWatcherMetricsHistory(duration string, since int64) ([]WatcherMetrics, error) {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	if !w.isStarted {
+		return nil, errors.New("need to call Start() first")
+	}
+
+	requested, err := ParseWindowDuration(duration)
+	if err != nil {
+		return nil, err
+	}
+	cache, ok := w.windows[requested]
+	if !ok {
+		return nil, errNotWatched(duration)
+	}
+
+	history := make([]WatcherMetrics, 0, len(cache.metrics))
+	for i := range cache.metrics {
+		if cache.metrics[i].Timestamp >= since {
+			history = append(history, *w.deepCopyWatcherMetrics(&cache.metrics[i]))
+		}
+	}
+	return history, nil
+}
+
+// QueryWatcherMetricsHistory Returns the history of the query window like GetWatcherMetricsHistory, with only the hosts
+// and metrics selected by the query, and no pod metrics like GetWatcherMetrics. The default window is used if the query has none
+func (w *Watcher) QueryWatcherMetricsHistory(query MetricsQuery, since int64) ([]WatcherMetrics, error) {
+	window := query.Window
+	if window == "" {
+		window = w.defaultWindow()
+	}
+	history, err := w.GetWatcherMetricsHistory(window, since)
+	if err != nil {
+		return nil, err
+	}
+	for i := range history {
+		filtered, err := query.Filter(&history[i])
+		if err != nil {
+			return nil, err
+		}
+		history[i] = *filtered
+	}
+	return history, nil
+}
+
+// WindowStatuses Returns the fetch status of every window, largest first
+func (w *Watcher) WindowStatuses() []WindowStatus {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	statuses := make([]WindowStatus, 0, len(w.durations))
+	for _, d := range w.durations {
+		cache := w.windows[d]
+		status := WindowStatus{
+			Window:              FormatWindowDuration(d),
+			LastSuccess:         cache.lastSuccess,
+			LastFailure:         cache.lastFailure,
+			LastError:           cache.lastError,
+			ConsecutiveFailures: cache.consecutiveFailures,
+			CacheSize:           len(cache.metrics),
+		}
+		if len(cache.metrics) > 0 {
+			latest := &cache.metrics[len(cache.metrics)-1]
+			status.LatestTimestamp = latest.Timestamp
+			status.Stale = w.isStale(latest)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// Returns true if metrics are older than the max age
+func (w *Watcher) isStale(metrics *WatcherMetrics) bool {
+	if w.maxAge <= 0 {
+		return false
+	}
+	return time.Since(time.Unix(metrics.Timestamp, 0)) > w.maxAge
+}
+
+// Windows Returns the window durations watched, largest first
+func (w *Watcher) Windows() []string {
+	windows := make([]string, 0, len(w.durations))
+	for _, d := range w.durations {
+		windows = append(windows, FormatWindowDuration(d))
+	}
+	return windows
+}
+
+// fallbackDurations Returns the requested duration followed by all smaller registered durations
+func (w *Watcher) fallbackDurations(requested time.Duration) []time.Duration {
+	for i, d := range w.durations {
+		if d == requested {
+			return w.durations[i:]
+		}
+	}
+	return nil
+}
+
+func (w *Watcher) appendWatcherMetrics(duration time.Duration, metric *WatcherMetrics) {
 	w.mutex.Lock()
 	defer w.mutex.Unlock()
-	if len(*recentMetrics) == w.cacheSize {
-		*recentMetrics = (*recentMetrics)[1:]
+	cache := w.windows[duration]
+	if len(cache.metrics) == w.cacheSize {
+		cache.metrics = cache.metrics[1:]
 	}
-	*recentMetrics = append(*recentMetrics, *metric)
+	cache.metrics = append(cache.metrics, *metric)
+	cache.lastSuccess = time.Now()
+	cache.consecutiveFailures = 0
+}
+
+func (w *Watcher) recordFetchFailure(duration time.Duration, err error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	cache := w.windows[duration]
+	cache.lastFailure = time.Now()
+	cache.lastError = err
+	cache.consecutiveFailures++
 }
 
 func (w *Watcher) deepCopyWatcherMetrics(src *WatcherMetrics) *WatcherMetrics {
@@ -240,7 +712,8 @@ func (w *Watcher) deepCopyWatcherMetrics(src *WatcherMetrics) *WatcherMetrics {
 	for host, fetchedMetric := range src.Data.NodeMetricsMap {
 		nodeMetric := NodeMetrics{
 			Metrics: make([]Metric, len(fetchedMetric.Metrics)),
-			Tags:    fetchedMetric.Tags,
+			Tags:    fetchedMetric.Tags.deepCopy(),
+			labels:  fetchedMetric.labels,
 		}
 		copy(nodeMetric.Metrics, fetchedMetric.Metrics)
 		nodeMetric.Metadata = fetchedMetric.Metadata
@@ -251,7 +724,9 @@ func (w *Watcher) deepCopyWatcherMetrics(src *WatcherMetrics) *WatcherMetrics {
 		Window:    src.Window,
 		Source:    src.Source,
 		Data: Data{
-			NodeMetricsMap: nodeMetricsMap,
+			NodeMetricsMap:      nodeMetricsMap,
+			PodMetricsMap:       src.Data.PodMetricsMap.deepCopy(),
+			NamespaceMetricsMap: src.Data.NamespaceMetricsMap.deepCopy(),
 		},
 	}
 }
@@ -259,9 +734,22 @@ func (w *Watcher) deepCopyWatcherMetrics(src *WatcherMetrics) *WatcherMetrics {
 // HTTP Handler for BaseUrl endpoint
 func (w *Watcher) handler(resp http.ResponseWriter, r *http.Request) {
 	resp.Header().Set("Content-Type", "application/json")
+	resp.Header().Set("Vary", "Accept, Accept-Encoding")
+	contentType := negotiateContentType(r.Header.Get("Accept"))
+	encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
 
-	metrics, err := w.GetLatestWatcherMetrics(FifteenMinutes)
-	if metrics == nil {
+	query, err := ParseMetricsQuery(r.URL.Query())
+	if err == nil && query.Window != "" && !w.isWatched(query.Window) {
+		err = errNotWatched(query.Window)
+	}
+	if err != nil {
+		resp.WriteHeader(http.StatusBadRequest)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	metrics, err := w.GetWatcherMetrics(query)
+	if metrics == nil || (err != nil && !errors.Is(err, ErrStaleMetrics)) {
 		if err != nil {
 			resp.WriteHeader(http.StatusInternalServerError)
 			log.Error(err)
@@ -271,46 +759,114 @@ func (w *Watcher) handler(resp http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	host := r.URL.Query().Get("host")
-	var bytes []byte
-	if host != "" {
-		if _, ok := metrics.Data.NodeMetricsMap[host]; ok {
-			hostMetricsData := make(map[string]NodeMetrics)
-			hostMetricsData[host] = metrics.Data.NodeMetricsMap[host]
-			hostMetrics := WatcherMetrics{Timestamp: metrics.Timestamp,
-				Window: metrics.Window,
-				Source: metrics.Source,
-				Data:   Data{NodeMetricsMap: hostMetricsData},
+	if query.SelectsNodes() && len(metrics.Data.NodeMetricsMap) == 0 {
+		resp.WriteHeader(http.StatusNotFound)
+		// Write out response for no metrics found
+		hosts := append([]string{}, query.Hosts...)
+		if query.HostsPattern != "" {
+			hosts = append(hosts, query.HostsPattern)
+		}
+		errString := fmt.Sprintf("No metrics found for host %s", strings.Join(hosts, ", "))
+		if query.LabelSelector != "" {
+			errString = fmt.Sprintf("No metrics found for hosts matching %s", query.LabelSelector)
+		}
+		resp.Write([]byte(errString))
+		return
+	}
+	resp.Header().Set("Content-Type", contentType)
+	if w.writeValidators(resp, r, metrics, contentType, encoding) {
+		return
+	}
+
+	status := http.StatusOK
+	if metrics.Stale {
+		// Stale metrics are still written out, but consumers should not act upon them
+		status = http.StatusServiceUnavailable
+	}
+	err = writeEncoded(resp, encoding, status, func(writer io.Writer) error {
+		if contentType == ProtobufContentType {
+			encoded, err := metrics.MarshalProtobuf()
+			if err != nil {
+				return err
 			}
-			bytes, err = gojay.MarshalJSONObject(&hostMetrics)
-		} else {
-			resp.WriteHeader(http.StatusNotFound)
-			// Write out response for no metrics found
-			errString := fmt.Sprintf("No metrics found for host %s", host)
-			byteArray := []byte(errString)
-			resp.Write(byteArray)
+			_, err = writer.Write(encoded)
+			return err
+		}
+		return encodeWatcherMetrics(writer, metrics)
+	})
+	if err != nil {
+		log.Error(err)
+	}
+}
+
+// HTTP Handler for HistoryUrl endpoint. It accepts the query parameters of the BaseUrl endpoint, and the since parameter
+func (w *Watcher) historyHandler(resp http.ResponseWriter, r *http.Request) {
+	resp.Header().Set("Content-Type", "application/json")
+	resp.Header().Set("Vary", "Accept-Encoding")
+
+	query, err := ParseMetricsQuery(r.URL.Query())
+	if err != nil {
+		resp.WriteHeader(http.StatusBadRequest)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+	window := query.Window
+	if window == "" {
+		window = w.defaultWindow()
+	}
+	if !w.isWatched(window) {
+		resp.WriteHeader(http.StatusBadRequest)
+		resp.Write([]byte(fmt.Sprintf("Window %s is not watched", window)))
+		return
+	}
+	var since int64
+	if sinceParam := r.URL.Query().Get(SinceParam); sinceParam != "" {
+		if since, err = strconv.ParseInt(sinceParam, 10, 64); err != nil {
+			resp.WriteHeader(http.StatusBadRequest)
+			resp.Write([]byte(fmt.Sprintf("Invalid since %s, expected unix seconds", sinceParam)))
 			return
 		}
-	} else {
-		bytes, err = gojay.MarshalJSONObject(metrics)
 	}
 
+	history, err := w.QueryWatcherMetricsHistory(query, since)
 	if err != nil {
 		log.Error(err)
 		resp.WriteHeader(http.StatusInternalServerError)
 		return
 	}
-
-	_, err = resp.Write(bytes)
+	encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+	err = writeEncoded(resp, encoding, http.StatusOK, func(writer io.Writer) error {
+		return gojay.NewEncoder(writer).EncodeArray(WatcherMetricsList(history))
+	})
 	if err != nil {
 		log.Error(err)
-		resp.WriteHeader(http.StatusInternalServerError)
 	}
 }
 
+// Returns the window served when none is requested: 15m if watched, else the largest window
+func (w *Watcher) defaultWindow() string {
+	if w.isWatched(FifteenMinutes) || len(w.durations) == 0 {
+		return FifteenMinutes
+	}
+	return FormatWindowDuration(w.durations[0])
+}
+
+func errNotWatched(window string) error {
+	return fmt.Errorf("window %s is not watched", window)
+}
+
+func (w *Watcher) isWatched(window string) bool {
+	duration, err := ParseWindowDuration(window)
+	if err != nil {
+		return false
+	}
+	_, ok := w.windows[duration]
+	return ok
+}
+
 // Simple server status handler
 func (w *Watcher) healthCheckHandler(resp http.ResponseWriter, r *http.Request) {
-	if status, err := w.client.Health(); status != 0 {
+	if status, err := w.client.HealthContext(r.Context()); status != 0 {
 		log.Warnf("health check failed with: %v", err)
 		resp.WriteHeader(http.StatusServiceUnavailable)
 		return
@@ -338,17 +894,58 @@ func metricMapToWatcherMetrics(metricMap map[string][]Metric, clientName string,
 	return watcherMetrics
 }
 
-func CurrentFifteenMinuteWindow() *Window {
+// CurrentWindow Returns the window of given duration ending now
+func CurrentWindow(duration time.Duration) *Window {
 	curTime := time.Now().Unix()
-	return &Window{FifteenMinutes, curTime - 15*60, curTime}
+	return &Window{FormatWindowDuration(duration), curTime - int64(duration/time.Second), curTime}
+}
+
+func CurrentFifteenMinuteWindow() *Window {
+	return CurrentWindow(15 * time.Minute)
 }
 
 func CurrentTenMinuteWindow() *Window {
-	curTime := time.Now().Unix()
-	return &Window{TenMinutes, curTime - 10*60, curTime}
+	return CurrentWindow(10 * time.Minute)
 }
 
 func CurrentFiveMinuteWindow() *Window {
-	curTime := time.Now().Unix()
-	return &Window{FiveMinutes, curTime - 5*60, curTime}
+	return CurrentWindow(5 * time.Minute)
+}
+
+// FormatWindowDuration Formats a window duration the way metrics providers expect it, e.g. 15m, 1h, 1h30m
+func FormatWindowDuration(duration time.Duration) string {
+	s := duration.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = s[:len(s)-2]
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = s[:len(s)-2]
+	}
+	return s
+}
+
+// ParseWindowDuration Parses a window duration such as 15m or 1h
+func ParseWindowDuration(duration string) (time.Duration, error) {
+	d, err := time.ParseDuration(duration)
+	if err != nil {
+		return 0, fmt.Errorf("invalid window duration %q: %v", duration, err)
+	}
+	return d, nil
+}
+
+// ParseWindowDurations Parses a comma separated list of window durations such as 15m,10m,5m
+func ParseWindowDurations(durations string) ([]time.Duration, error) {
+	var windows []time.Duration
+	for _, duration := range strings.Split(durations, ",") {
+		duration = strings.TrimSpace(duration)
+		if duration == "" {
+			continue
+		}
+		d, err := ParseWindowDuration(duration)
+		if err != nil {
+			return nil, err
+		}
+		windows = append(windows, d)
+	}
+	return windows, nil
 }
@@ -43,9 +43,32 @@ func (s Metrices) IsNil() bool {
 	return len(s) == 0
 }
 
+type WatcherMetricsList []WatcherMetrics
+
+func (s *WatcherMetricsList) UnmarshalJSONArray(dec *gojay.Decoder) error {
+	var value = WatcherMetrics{Data: Data{NodeMetricsMap: make(map[string]NodeMetrics)}}
+	if err := dec.Object(&value); err != nil {
+		return err
+	}
+	*s = append(*s, value)
+	return nil
+}
+
+func (s WatcherMetricsList) MarshalJSONArray(enc *gojay.Encoder) {
+	for i := range s {
+		enc.Object(&s[i])
+	}
+}
+
+func (s WatcherMetricsList) IsNil() bool {
+	return len(s) == 0
+}
+
 // MarshalJSONObject implements MarshalerJSONObject
 func (d *Data) MarshalJSONObject(enc *gojay.Encoder) {
 	enc.ObjectKey("NodeMetricsMap", &d.NodeMetricsMap)
+	enc.ObjectKeyOmitEmpty("PodMetricsMap", &d.PodMetricsMap)
+	enc.ObjectKeyOmitEmpty("NamespaceMetricsMap", &d.NamespaceMetricsMap)
 }
 
 // IsNil checks if instance is nil
@@ -60,16 +83,32 @@ func (d *Data) UnmarshalJSONObject(dec *gojay.Decoder, k string) error {
 	case "NodeMetricsMap":
 		err := dec.Object(&d.NodeMetricsMap)
 		return err
+
+	case "PodMetricsMap":
+		if d.PodMetricsMap == nil {
+			d.PodMetricsMap = make(PodMetricsMap)
+		}
+		return dec.Object(&d.PodMetricsMap)
+
+	case "NamespaceMetricsMap":
+		if d.NamespaceMetricsMap == nil {
+			d.NamespaceMetricsMap = make(NamespaceMetricsMap)
+		}
+		return dec.Object(&d.NamespaceMetricsMap)
 	}
 	return nil
 }
 
 // NKeys returns the number of keys to unmarshal
-func (d *Data) NKeys() int { return 1 }
+func (d *Data) NKeys() int { return 3 }
 
 // MarshalJSONObject implements MarshalerJSONObject
 func (m *Metadata) MarshalJSONObject(enc *gojay.Encoder) {
 	enc.StringKey("dataCenter", m.DataCenter)
+	enc.StringKeyOmitEmpty("zone", m.Zone)
+	enc.StringKeyOmitEmpty("region", m.Region)
+	enc.StringKeyOmitEmpty("instanceType", m.InstanceType)
+	enc.StringKeyOmitEmpty("pool", m.NodePool)
 }
 
 // IsNil checks if instance is nil
@@ -84,12 +123,24 @@ func (m *Metadata) UnmarshalJSONObject(dec *gojay.Decoder, k string) error {
 	case "dataCenter":
 		return dec.String(&m.DataCenter)
 
+	case "zone":
+		return dec.String(&m.Zone)
+
+	case "region":
+		return dec.String(&m.Region)
+
+	case "instanceType":
+		return dec.String(&m.InstanceType)
+
+	case "pool":
+		return dec.String(&m.NodePool)
+
 	}
 	return nil
 }
 
 // NKeys returns the number of keys to unmarshal
-func (m *Metadata) NKeys() int { return 1 }
+func (m *Metadata) NKeys() int { return 5 }
 
 // MarshalJSONObject implements MarshalerJSONObject
 func (m *Metric) MarshalJSONObject(enc *gojay.Encoder) {
@@ -199,8 +250,131 @@ func (m *NodeMetricsMap) UnmarshalJSONObject(dec *gojay.Decoder, k string) error
 func (m *NodeMetricsMap) NKeys() int { return 0 }
 
 // MarshalJSONObject implements MarshalerJSONObject
-func (t *Tags) MarshalJSONObject(enc *gojay.Encoder) {
+func (m *PodMetrics) MarshalJSONObject(enc *gojay.Encoder) {
+	enc.StringKey("namespace", m.Namespace)
+	enc.StringKey("name", m.Name)
+	enc.StringKeyOmitEmpty("node", m.Node)
+	enc.ArrayKey("metrics", Metrices(m.Metrics))
+}
+
+// IsNil checks if instance is nil
+func (m *PodMetrics) IsNil() bool {
+	return m == nil
+}
+
+// UnmarshalJSONObject implements gojay's UnmarshalerJSONObject
+func (m *PodMetrics) UnmarshalJSONObject(dec *gojay.Decoder, k string) error {
 
+	switch k {
+	case "namespace":
+		return dec.String(&m.Namespace)
+
+	case "name":
+		return dec.String(&m.Name)
+
+	case "node":
+		return dec.String(&m.Node)
+
+	case "metrics":
+		var aSlice = Metrices{}
+		err := dec.Array(&aSlice)
+		if err == nil && len(aSlice) > 0 {
+			m.Metrics = []Metric(aSlice)
+		}
+		return err
+
+	}
+	return nil
+}
+
+// NKeys returns the number of keys to unmarshal
+func (m *PodMetrics) NKeys() int { return 4 }
+
+// MarshalJSONObject implements MarshalerJSONObject
+func (m *PodMetricsMap) MarshalJSONObject(enc *gojay.Encoder) {
+	for k, v := range *m {
+		enc.ObjectKey(k, &v)
+	}
+}
+
+// IsNil checks if instance is nil or empty, so empty maps are omitted
+func (m *PodMetricsMap) IsNil() bool {
+	return m == nil || len(*m) == 0
+}
+
+// UnmarshalJSONObject implements gojay's UnmarshalerJSONObject
+func (m *PodMetricsMap) UnmarshalJSONObject(dec *gojay.Decoder, k string) error {
+	var value PodMetrics
+	if err := dec.Object(&value); err != nil {
+		return err
+	}
+	(*m)[k] = value
+	return nil
+}
+
+// NKeys returns the number of keys to unmarshal
+func (m *PodMetricsMap) NKeys() int { return 0 }
+
+// MarshalJSONObject implements MarshalerJSONObject
+func (m *NamespaceMetrics) MarshalJSONObject(enc *gojay.Encoder) {
+	enc.ArrayKey("metrics", Metrices(m.Metrics))
+}
+
+// IsNil checks if instance is nil
+func (m *NamespaceMetrics) IsNil() bool {
+	return m == nil
+}
+
+// UnmarshalJSONObject implements gojay's UnmarshalerJSONObject
+func (m *NamespaceMetrics) UnmarshalJSONObject(dec *gojay.Decoder, k string) error {
+
+	switch k {
+	case "metrics":
+		var aSlice = Metrices{}
+		err := dec.Array(&aSlice)
+		if err == nil && len(aSlice) > 0 {
+			m.Metrics = []Metric(aSlice)
+		}
+		return err
+
+	}
+	return nil
+}
+
+// NKeys returns the number of keys to unmarshal
+func (m *NamespaceMetrics) NKeys() int { return 1 }
+
+// MarshalJSONObject implements MarshalerJSONObject
+func (m *NamespaceMetricsMap) MarshalJSONObject(enc *gojay.Encoder) {
+	for k, v := range *m {
+		enc.ObjectKey(k, &v)
+	}
+}
+
+// IsNil checks if instance is nil or empty, so empty maps are omitted
+func (m *NamespaceMetricsMap) IsNil() bool {
+	return m == nil || len(*m) == 0
+}
+
+// UnmarshalJSONObject implements gojay's UnmarshalerJSONObject
+func (m *NamespaceMetricsMap) UnmarshalJSONObject(dec *gojay.Decoder, k string) error {
+	var value NamespaceMetrics
+	if err := dec.Object(&value); err != nil {
+		return err
+	}
+	(*m)[k] = value
+	return nil
+}
+
+// NKeys returns the number of keys to unmarshal
+func (m *NamespaceMetricsMap) NKeys() int { return 0 }
+
+// MarshalJSONObject implements MarshalerJSONObject
+func (t *Tags) MarshalJSONObject(enc *gojay.Encoder) {
+	enc.ObjectKeyOmitEmpty("labels", (*tagLabels)(&t.Labels))
+	if len(t.Taints) > 0 {
+		enc.SliceStringKey("taints", t.Taints)
+	}
 }
 
 // IsNil checks if instance is nil
@@ -212,13 +386,47 @@ func (t *Tags) IsNil() bool {
 func (t *Tags) UnmarshalJSONObject(dec *gojay.Decoder, k string) error {
 
 	switch k {
+	case "labels":
+		t.Labels = make(map[string]string)
+		return dec.Object((*tagLabels)(&t.Labels))
+
+	case "taints":
+		return dec.SliceString(&t.Taints)
 
 	}
 	return nil
 }
 
 // NKeys returns the number of keys to unmarshal
-func (t *Tags) NKeys() int { return 0 }
+func (t *Tags) NKeys() int { return 2 }
+
+// Labels of Tags, as a JSON object
+type tagLabels map[string]string
+
+// MarshalJSONObject implements MarshalerJSONObject
+func (l *tagLabels) MarshalJSONObject(enc *gojay.Encoder) {
+	for k, v := range *l {
+		enc.StringKey(k, v)
+	}
+}
+
+// IsNil checks if instance is nil or empty, so empty labels are omitted
+func (l *tagLabels) IsNil() bool {
+	return l == nil || len(*l) == 0
+}
+
+// UnmarshalJSONObject implements gojay's UnmarshalerJSONObject
+func (l *tagLabels) UnmarshalJSONObject(dec *gojay.Decoder, k string) error {
+	var value string
+	if err := dec.String(&value); err != nil {
+		return err
+	}
+	(*l)[k] = value
+	return nil
+}
+
+// NKeys returns the number of keys to unmarshal
+func (l *tagLabels) NKeys() int { return 0 }
 
 // MarshalJSONObject implements MarshalerJSONObject
 func (m *WatcherMetrics) MarshalJSONObject(enc *gojay.Encoder) {
@@ -226,6 +434,7 @@ func (m *WatcherMetrics) MarshalJSONObject(enc *gojay.Encoder) {
 	enc.ObjectKey("window", &m.Window)
 	enc.StringKey("source", m.Source)
 	enc.ObjectKey("data", &m.Data)
+	enc.BoolKeyOmitEmpty("stale", m.Stale)
 }
 
 // IsNil checks if instance is nil
@@ -253,12 +462,15 @@ func (m *WatcherMetrics) UnmarshalJSONObject(dec *gojay.Decoder, k string) error
 
 		return err
 
+	case "stale":
+		return dec.Bool(&m.Stale)
+
 	}
 	return nil
 }
 
 // NKeys returns the number of keys to unmarshal
-func (m *WatcherMetrics) NKeys() int { return 4 }
+func (m *WatcherMetrics) NKeys() int { return 5 }
 
 // MarshalJSONObject implements MarshalerJSONObject
 func (w *Window) MarshalJSONObject(enc *gojay.Encoder) {
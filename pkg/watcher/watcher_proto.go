@@ -0,0 +1,172 @@
+/*
+Copyright 2020 PayPal
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+//go:generate protoc --proto_path=schema --go_out=schema --go_opt=paths=source_relative watcher.proto
+
+import (
+	"github.com/paypal/load-watcher/pkg/watcher/schema"
+	"google.golang.org/protobuf/proto"
+)
+
+// MarshalProtobuf encodes metrics in the protobuf wire format of schema/watcher.proto
+func (m *WatcherMetrics) MarshalProtobuf() ([]byte, error) {
+	return proto.Marshal(m.toProto())
+}
+
+// UnmarshalProtobuf decodes metrics from the protobuf wire format of schema/watcher.proto
+func (m *WatcherMetrics) UnmarshalProtobuf(b []byte) error {
+	var pb schema.WatcherMetrics
+	if err := proto.Unmarshal(b, &pb); err != nil {
+		return err
+	}
+	*m = watcherMetricsFromProto(&pb)
+	return nil
+}
+
+func (m *WatcherMetrics) toProto() *schema.WatcherMetrics {
+	pb := &schema.WatcherMetrics{
+		Timestamp: m.Timestamp,
+		Window:    &schema.Window{Duration: m.Window.Duration, Start: m.Window.Start, End: m.Window.End},
+		Source:    m.Source,
+		Data: &schema.Data{
+			NodeMetricsMap: make(map[string]*schema.NodeMetrics, len(m.Data.NodeMetricsMap)),
+		},
+		Stale: m.Stale,
+	}
+	for host, node := range m.Data.NodeMetricsMap {
+		pb.Data.NodeMetricsMap[host] = &schema.NodeMetrics{
+			Metrics: metricsToProto(node.Metrics),
+			Tags:    &schema.Tags{Labels: node.Tags.Labels, Taints: node.Tags.Taints},
+			Metadata: &schema.Metadata{
+				DataCenter:   node.Metadata.DataCenter,
+				Zone:         node.Metadata.Zone,
+				Region:       node.Metadata.Region,
+				InstanceType: node.Metadata.InstanceType,
+				Pool:         node.Metadata.NodePool,
+			},
+		}
+	}
+	if len(m.Data.PodMetricsMap) > 0 {
+		pb.Data.PodMetricsMap = make(map[string]*schema.PodMetrics, len(m.Data.PodMetricsMap))
+		for key, pod := range m.Data.PodMetricsMap {
+			pb.Data.PodMetricsMap[key] = &schema.PodMetrics{
+				Namespace: pod.Namespace,
+				Name:      pod.Name,
+				Node:      pod.Node,
+				Metrics:   metricsToProto(pod.Metrics),
+			}
+		}
+	}
+	if len(m.Data.NamespaceMetricsMap) > 0 {
+		pb.Data.NamespaceMetricsMap = make(map[string]*schema.NamespaceMetrics, len(m.Data.NamespaceMetricsMap))
+		for namespace, metrics := range m.Data.NamespaceMetricsMap {
+			pb.Data.NamespaceMetricsMap[namespace] = &schema.NamespaceMetrics{Metrics: metricsToProto(metrics.Metrics)}
+		}
+	}
+	return pb
+}
+
+func metricsToProto(metrics []Metric) []*schema.Metric {
+	if len(metrics) == 0 {
+		return nil
+	}
+	pb := make([]*schema.Metric, len(metrics))
+	for i, metric := range metrics {
+		pb[i] = &schema.Metric{
+			Name:     metric.Name,
+			Type:     metric.Type,
+			Operator: metric.Operator,
+			Rollup:   metric.Rollup,
+			Value:    metric.Value,
+		}
+	}
+	return pb
+}
+
+// Returns the metrics of pb, leaving empty pod and namespace maps, metrics, labels and taints nil like gojay decoding does
+func watcherMetricsFromProto(pb *schema.WatcherMetrics) WatcherMetrics {
+	m := WatcherMetrics{
+		Timestamp: pb.GetTimestamp(),
+		Window: Window{
+			Duration: pb.GetWindow().GetDuration(),
+			Start:    pb.GetWindow().GetStart(),
+			End:      pb.GetWindow().GetEnd(),
+		},
+		Source: pb.GetSource(),
+		Data: Data{
+			NodeMetricsMap: make(NodeMetricsMap, len(pb.GetData().GetNodeMetricsMap())),
+		},
+		Stale: pb.GetStale(),
+	}
+	for host, node := range pb.GetData().GetNodeMetricsMap() {
+		metadata := node.GetMetadata()
+		m.Data.NodeMetricsMap[host] = NodeMetrics{
+			Metrics: metricsFromProto(node.GetMetrics()),
+			Tags:    Tags{Labels: nilIfEmpty(node.GetTags().GetLabels()), Taints: node.GetTags().GetTaints()},
+			Metadata: Metadata{
+				DataCenter:   metadata.GetDataCenter(),
+				Zone:         metadata.GetZone(),
+				Region:       metadata.GetRegion(),
+				InstanceType: metadata.GetInstanceType(),
+				NodePool:     metadata.GetPool(),
+			},
+		}
+	}
+	if pods := pb.GetData().GetPodMetricsMap(); len(pods) > 0 {
+		m.Data.PodMetricsMap = make(PodMetricsMap, len(pods))
+		for key, pod := range pods {
+			m.Data.PodMetricsMap[key] = PodMetrics{
+				Namespace: pod.GetNamespace(),
+				Name:      pod.GetName(),
+				Node:      pod.GetNode(),
+				Metrics:   metricsFromProto(pod.GetMetrics()),
+			}
+		}
+	}
+	if namespaces := pb.GetData().GetNamespaceMetricsMap(); len(namespaces) > 0 {
+		m.Data.NamespaceMetricsMap = make(NamespaceMetricsMap, len(namespaces))
+		for namespace, metrics := range namespaces {
+			m.Data.NamespaceMetricsMap[namespace] = NamespaceMetrics{Metrics: metricsFromProto(metrics.GetMetrics())}
+		}
+	}
+	return m
+}
+
+func metricsFromProto(pb []*schema.Metric) []Metric {
+	if len(pb) == 0 {
+		return nil
+	}
+	metrics := make([]Metric, len(pb))
+	for i, metric := range pb {
+		metrics[i] = Metric{
+			Name:     metric.GetName(),
+			Type:     metric.GetType(),
+			Operator: metric.GetOperator(),
+			Rollup:   metric.GetRollup(),
+			Value:    metric.GetValue(),
+		}
+	}
+	return metrics
+}
+
+func nilIfEmpty(labels map[string]string) map[string]string {
+	if len(labels) == 0 {
+		return nil
+	}
+	return labels
+}
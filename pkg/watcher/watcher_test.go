@@ -17,13 +17,24 @@ limitations under the License.
 package watcher
 
 import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/francoispqt/gojay"
+	"github.com/klauspost/compress/zstd"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -48,6 +59,219 @@ func TestGetLatestWatcherMetrics(t *testing.T) {
 	assert.Equal(t, FiveMinutesMetricsMap[SecondNode], metrics.Data.NodeMetricsMap[SecondNode].Metrics)
 }
 
+func TestGetLatestWatcherMetricsCustomWindows(t *testing.T) {
+	client := NewTestMetricsServerClient()
+	customWatcher := NewWatcher(client, WatcherOpts{Windows: []time.Duration{time.Minute, time.Hour, 30 * time.Minute, time.Minute}})
+	assert.Equal(t, []string{"1h", "30m", "1m"}, customWatcher.Windows())
+
+	// Only the 1m window has metrics, so both larger windows fall back to it
+	customWatcher.isStarted = true
+	metrics := metricMapToWatcherMetrics(FiveMinutesMetricsMap, client.Name(), *CurrentWindow(time.Minute))
+	customWatcher.appendWatcherMetrics(time.Minute, &metrics)
+
+	for _, duration := range []string{"1h", "30m", "1m"} {
+		latest, err := customWatcher.GetLatestWatcherMetrics(duration)
+		require.Nil(t, err)
+		assert.Equal(t, "1m", latest.Window.Duration)
+	}
+
+	_, err := customWatcher.GetLatestWatcherMetrics(FifteenMinutes)
+	assert.NotNil(t, err)
+}
+
+func TestGetWatcherMetricsHistory(t *testing.T) {
+	client := NewTestMetricsServerClient()
+	historyWatcher := NewWatcher(client, WatcherOpts{CacheSize: 2})
+	historyWatcher.isStarted = true
+	for _, timestamp := range []int64{100, 200, 300} {
+		metrics := metricMapToWatcherMetrics(FifteenMinutesMetricsMap, client.Name(), *CurrentFifteenMinuteWindow())
+		metrics.Timestamp = timestamp
+		historyWatcher.appendWatcherMetrics(15*time.Minute, &metrics)
+	}
+
+	history, err := historyWatcher.GetWatcherMetricsHistory(FifteenMinutes, 0)
+	require.Nil(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, int64(200), history[0].Timestamp)
+	assert.Equal(t, int64(300), history[1].Timestamp)
+
+	history, err = historyWatcher.GetWatcherMetricsHistory(FifteenMinutes, 250)
+	require.Nil(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, int64(300), history[0].Timestamp)
+
+	history, err = historyWatcher.GetWatcherMetricsHistory(FiveMinutes, 0)
+	require.Nil(t, err)
+	assert.Empty(t, history)
+
+	_, err = historyWatcher.GetWatcherMetricsHistory("1h", 0)
+	assert.NotNil(t, err)
+}
+
+func TestWatcherHistoryAPI(t *testing.T) {
+	uri, _ := url.Parse(HistoryUrl)
+	q := uri.Query()
+	q.Set("window", TenMinutes)
+	uri.RawQuery = q.Encode()
+	req, err := http.NewRequest("GET", uri.String(), nil)
+	require.Nil(t, err)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(w.historyHandler)
+
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	expectedHistory, err := w.GetWatcherMetricsHistory(TenMinutes, 0)
+	require.Nil(t, err)
+	var history WatcherMetricsList
+	err = gojay.UnmarshalJSONArray(rr.Body.Bytes(), &history)
+	require.Nil(t, err)
+	assert.Equal(t, WatcherMetricsList(expectedHistory), history)
+
+	// History is filtered like the latest metrics
+	req, err = http.NewRequest("GET", HistoryUrl+"?window=10m&host="+FirstNode+"&type=CPU", nil)
+	require.Nil(t, err)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	history = nil
+	require.Nil(t, gojay.UnmarshalJSONArray(rr.Body.Bytes(), &history))
+	require.Len(t, history, len(expectedHistory))
+	for _, metrics := range history {
+		require.Len(t, metrics.Data.NodeMetricsMap, 1)
+		for _, metric := range metrics.Data.NodeMetricsMap[FirstNode].Metrics {
+			assert.Equal(t, CPU, metric.Type)
+		}
+	}
+
+	for _, query := range []string{"window=1h", "since=yesterday", "hosts=("} {
+		req, err = http.NewRequest("GET", HistoryUrl+"?"+query, nil)
+		require.Nil(t, err)
+		rr = httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	}
+}
+
+// Fails every fetch
+type failingClient struct {
+	MetricsProviderClient
+}
+
+func (c failingClient) FetchAllHostsMetrics(window *Window) (map[string][]Metric, error) {
+	return nil, errors.New("metrics provider unavailable")
+}
+
+func TestWatcherStaleMetrics(t *testing.T) {
+	client := failingClient{MetricsProviderClient: NewTestMetricsServerClient()}
+	staleWatcher := NewWatcher(client, WatcherOpts{MaxAge: time.Minute})
+	staleWatcher.isStarted = true
+
+	metrics := metricMapToWatcherMetrics(FifteenMinutesMetricsMap, client.Name(), *CurrentFifteenMinuteWindow())
+	metrics.Timestamp = time.Now().Add(-time.Hour).Unix()
+	staleWatcher.appendWatcherMetrics(15*time.Minute, &metrics)
+	staleWatcher.fetchOnce(context.Background(), 15*time.Minute)
+	staleWatcher.fetchOnce(context.Background(), 15*time.Minute)
+
+	latest, err := staleWatcher.GetLatestWatcherMetrics(FifteenMinutes)
+	assert.True(t, errors.Is(err, ErrStaleMetrics))
+	require.NotNil(t, latest)
+	assert.True(t, latest.Stale)
+	assert.Equal(t, FifteenMinutesMetricsMap[FirstNode], latest.Data.NodeMetricsMap[FirstNode].Metrics)
+
+	statuses := staleWatcher.WindowStatuses()
+	require.Len(t, statuses, 3)
+	assert.Equal(t, FifteenMinutes, statuses[0].Window)
+	assert.Equal(t, 2, statuses[0].ConsecutiveFailures)
+	assert.NotNil(t, statuses[0].LastError)
+	assert.Equal(t, 1, statuses[0].CacheSize)
+	assert.True(t, statuses[0].Stale)
+
+	rr := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", BaseUrl, nil)
+	require.Nil(t, err)
+	http.HandlerFunc(staleWatcher.handler).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	var watcherMetrics = &WatcherMetrics{Data: Data{NodeMetricsMap: make(map[string]NodeMetrics)}}
+	require.Nil(t, gojay.UnmarshalJSONObject(rr.Body.Bytes(), watcherMetrics))
+	assert.True(t, watcherMetrics.Stale)
+
+	// Fresh metrics of a smaller window are preferred over stale ones
+	metrics = metricMapToWatcherMetrics(TenMinutesMetricsMap, client.Name(), *CurrentTenMinuteWindow())
+	staleWatcher.appendWatcherMetrics(10*time.Minute, &metrics)
+	latest, err = staleWatcher.GetLatestWatcherMetrics(FifteenMinutes)
+	require.Nil(t, err)
+	assert.False(t, latest.Stale)
+	assert.Equal(t, TenMinutes, latest.Window.Duration)
+
+	// A successful fetch resets the failure count
+	staleWatcher.appendWatcherMetrics(15*time.Minute, &metrics)
+	assert.Equal(t, 0, staleWatcher.WindowStatuses()[0].ConsecutiveFailures)
+}
+
+// Returns the metrics of the test server with a partial metrics error
+type partialClient struct {
+	MetricsProviderClient
+}
+
+func (c partialClient) FetchAllHostsMetrics(window *Window) (map[string][]Metric, error) {
+	metrics, _ := c.MetricsProviderClient.FetchAllHostsMetrics(window)
+	return metrics, &PartialMetricsError{Err: errors.New("1 of 2 queries failed")}
+}
+
+func TestWatcherCachesPartialMetrics(t *testing.T) {
+	client := partialClient{MetricsProviderClient: NewTestMetricsServerClient()}
+	partialWatcher := NewWatcher(client, WatcherOpts{})
+	partialWatcher.isStarted = true
+	partialWatcher.fetchOnce(context.Background(), 15*time.Minute)
+
+	latest, err := partialWatcher.GetLatestWatcherMetrics(FifteenMinutes)
+	require.Nil(t, err)
+	assert.Equal(t, FifteenMinutesMetricsMap[FirstNode], latest.Data.NodeMetricsMap[FirstNode].Metrics)
+	assert.Equal(t, 0, partialWatcher.WindowStatuses()[0].ConsecutiveFailures)
+}
+
+// Returns the metrics of the test server as supplied by a backend of the window
+type sourceClient struct {
+	MetricsProviderClient
+}
+
+func (c sourceClient) FetchSource(window *Window) string {
+	if window.Duration == FiveMinutes {
+		return ""
+	}
+	return "backend-" + window.Duration
+}
+
+func TestWatcherRecordsFetchSource(t *testing.T) {
+	sourceWatcher := NewWatcher(sourceClient{MetricsProviderClient: NewTestMetricsServerClient()}, WatcherOpts{})
+	sourceWatcher.isStarted = true
+	sourceWatcher.fetchOnce(context.Background(), 15*time.Minute)
+	sourceWatcher.fetchOnce(context.Background(), 5*time.Minute)
+
+	latest, err := sourceWatcher.GetLatestWatcherMetrics(FifteenMinutes)
+	require.Nil(t, err)
+	assert.Equal(t, "backend-15m", latest.Source)
+	// The client name is recorded if the source is unknown
+	latest, err = sourceWatcher.GetLatestWatcherMetrics(FiveMinutes)
+	require.Nil(t, err)
+	assert.Equal(t, TestServerClientName, latest.Source)
+}
+
+func TestFormatWindowDuration(t *testing.T) {
+	assert.Equal(t, FifteenMinutes, FormatWindowDuration(15*time.Minute))
+	assert.Equal(t, "1h", FormatWindowDuration(time.Hour))
+	assert.Equal(t, "1h30m", FormatWindowDuration(90*time.Minute))
+	assert.Equal(t, "30s", FormatWindowDuration(30*time.Second))
+
+	windows, err := ParseWindowDurations("1m, 30m,1h")
+	require.Nil(t, err)
+	assert.Equal(t, []time.Duration{time.Minute, 30 * time.Minute, time.Hour}, windows)
+	_, err = ParseWindowDurations("15x")
+	assert.NotNil(t, err)
+}
+
 func TestWatcherAPIAllHosts(t *testing.T) {
 	req, err := http.NewRequest("GET", BaseUrl, nil)
 	require.Nil(t, err)
@@ -91,6 +315,204 @@ func TestWatcherAPISingleHost(t *testing.T) {
 	assert.Equal(t, expectedMetrics.Source, watcherMetrics.Source)
 }
 
+func TestWatcherAPIQuery(t *testing.T) {
+	uri, _ := url.Parse(BaseUrl)
+	q := uri.Query()
+	q.Set(WindowParam, FiveMinutes)
+	q.Add(HostParam, FirstNode)
+	q.Add(HostParam, SecondNode)
+	q.Set(TypeParam, CPU)
+	uri.RawQuery = q.Encode()
+	req, err := http.NewRequest("GET", uri.String(), nil)
+	require.Nil(t, err)
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(w.handler)
+
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	data := Data{NodeMetricsMap: make(map[string]NodeMetrics)}
+	var watcherMetrics = &WatcherMetrics{Data: data}
+	err = gojay.UnmarshalJSONObject(rr.Body.Bytes(), watcherMetrics)
+	require.Nil(t, err)
+	assert.Equal(t, FiveMinutes, watcherMetrics.Window.Duration)
+	assert.Equal(t, FiveMinutesMetricsMap[FirstNode], watcherMetrics.Data.NodeMetricsMap[FirstNode].Metrics)
+	assert.Equal(t, FiveMinutesMetricsMap[SecondNode], watcherMetrics.Data.NodeMetricsMap[SecondNode].Metrics)
+
+	// No metrics of the requested type
+	q.Set(TypeParam, Memory)
+	uri.RawQuery = q.Encode()
+	req, err = http.NewRequest("GET", uri.String(), nil)
+	require.Nil(t, err)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	for _, query := range []string{"window=1h", "window=fifteen", "hosts=worker-("} {
+		req, err = http.NewRequest("GET", BaseUrl+"?"+query, nil)
+		require.Nil(t, err)
+		rr = httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestWatcherConditionalRequests(t *testing.T) {
+	serve := func(url string, ifNoneMatch string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("GET", url, nil)
+		require.Nil(t, err)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(w.handler).ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := serve(BaseUrl, "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	etag := rr.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+	latest, err := w.GetLatestWatcherMetrics(FifteenMinutes)
+	require.Nil(t, err)
+	assert.Equal(t, time.Unix(latest.Timestamp, 0).UTC().Format(http.TimeFormat), rr.Header().Get("Last-Modified"))
+	maxAge, err := strconv.Atoi(strings.TrimPrefix(rr.Header().Get("Cache-Control"), "max-age="))
+	require.Nil(t, err)
+	assert.LessOrEqual(t, maxAge, int(w.fetchInterval.Seconds()))
+
+	for _, ifNoneMatch := range []string{etag, `"other", W/` + etag, "*"} {
+		rr = serve(BaseUrl, ifNoneMatch)
+		assert.Equal(t, http.StatusNotModified, rr.Code, ifNoneMatch)
+		assert.Empty(t, rr.Body.String())
+		assert.Equal(t, etag, rr.Header().Get("ETag"))
+	}
+	assert.Equal(t, http.StatusOK, serve(BaseUrl, `"other"`).Code)
+	// Snapshots of other windows have other ETags
+	rr = serve(BaseUrl+"?window="+FiveMinutes, etag)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.NotEqual(t, etag, rr.Header().Get("ETag"))
+
+	// Stale metrics are not fresh, and change the ETag
+	stale := *latest
+	stale.Stale = true
+	assert.NotEqual(t, etag, metricsETag(&stale))
+	assert.Equal(t, time.Duration(0), w.freshFor(&stale))
+}
+
+func TestWatcherCompressedResponses(t *testing.T) {
+	expectedMetrics, err := w.GetLatestWatcherMetrics(FifteenMinutes)
+	require.Nil(t, err)
+	etags := make(map[string]string)
+	for acceptEncoding, encoding := range map[string]string{
+		"":                       "",
+		"gzip":                   GzipEncoding,
+		"zstd":                   ZstdEncoding,
+		AcceptEncoding:           ZstdEncoding,
+		"gzip;q=1.0, zstd;q=0.5": GzipEncoding,
+		"*":                      ZstdEncoding,
+		"*, zstd;q=0":            GzipEncoding,
+		"br":                     "",
+		"gzip;q=0.2, identity":   "",
+	} {
+		req, err := http.NewRequest("GET", BaseUrl, nil)
+		require.Nil(t, err)
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(w.handler).ServeHTTP(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code, acceptEncoding)
+		assert.Equal(t, encoding, rr.Header().Get("Content-Encoding"), acceptEncoding)
+		assert.Equal(t, "Accept, Accept-Encoding", rr.Header().Get("Vary"))
+		etags[encoding] = rr.Header().Get("ETag")
+
+		var body io.Reader = rr.Body
+		switch encoding {
+		case GzipEncoding:
+			body, err = gzip.NewReader(rr.Body)
+			require.Nil(t, err)
+		case ZstdEncoding:
+			decoder, err := zstd.NewReader(rr.Body)
+			require.Nil(t, err)
+			defer decoder.Close()
+			body = decoder
+		}
+		watcherMetrics := &WatcherMetrics{Data: Data{NodeMetricsMap: make(map[string]NodeMetrics)}}
+		require.Nil(t, gojay.NewDecoder(body).DecodeObject(watcherMetrics), acceptEncoding)
+		assert.Equal(t, expectedMetrics, watcherMetrics, acceptEncoding)
+	}
+	// Each encoding is another representation, with its own ETag
+	assert.Len(t, etags, 3)
+	assert.NotEqual(t, etags[""], etags[GzipEncoding])
+	assert.NotEqual(t, etags[GzipEncoding], etags[ZstdEncoding])
+
+	req, err := http.NewRequest("GET", BaseUrl, nil)
+	require.Nil(t, err)
+	req.Header.Set("Accept-Encoding", ZstdEncoding)
+	req.Header.Set("If-None-Match", etags[ZstdEncoding])
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(w.handler).ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNotModified, rr.Code)
+}
+
+func TestWatcherProtobufResponses(t *testing.T) {
+	expectedMetrics, err := w.GetLatestWatcherMetrics(FifteenMinutes)
+	require.Nil(t, err)
+	serve := func(accept string, ifNoneMatch string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("GET", BaseUrl, nil)
+		require.Nil(t, err)
+		req.Header.Set("Accept", accept)
+		req.Header.Set("If-None-Match", ifNoneMatch)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(w.handler).ServeHTTP(rr, req)
+		return rr
+	}
+
+	for accept, contentType := range map[string]string{
+		"":                                  JSONContentType,
+		"*/*":                               JSONContentType,
+		JSONContentType:                     JSONContentType,
+		ProtobufContentType:                 ProtobufContentType,
+		AcceptProtobuf:                      ProtobufContentType,
+		"application/x-protobuf;q=0.5, */*": JSONContentType,
+	} {
+		rr := serve(accept, "")
+		require.Equal(t, http.StatusOK, rr.Code, accept)
+		assert.Equal(t, contentType, rr.Header().Get("Content-Type"), accept)
+		if contentType == ProtobufContentType {
+			watcherMetrics := &WatcherMetrics{}
+			require.Nil(t, watcherMetrics.UnmarshalProtobuf(rr.Body.Bytes()))
+			assert.Equal(t, expectedMetrics, watcherMetrics)
+		}
+	}
+
+	// Protobuf responses have their own ETag
+	etag := serve(ProtobufContentType, "").Header().Get("ETag")
+	assert.NotEqual(t, serve(JSONContentType, "").Header().Get("ETag"), etag)
+	assert.Equal(t, http.StatusNotModified, serve(ProtobufContentType, etag).Code)
+	assert.Equal(t, http.StatusOK, serve(JSONContentType, etag).Code)
+}
+
+func TestEncodeWatcherMetrics(t *testing.T) {
+	for _, nodes := range []int{0, 1, 3} {
+		metrics := &WatcherMetrics{
+			Timestamp: 1556987522,
+			Window:    Window{Duration: FifteenMinutes, Start: 1556986622, End: 1556987522},
+			Source:    "test",
+			Data:      Data{NodeMetricsMap: make(NodeMetricsMap)},
+		}
+		for i := 0; i < nodes; i++ {
+			metrics.Data.NodeMetricsMap["node-"+strconv.Itoa(i)] = NodeMetrics{
+				Metrics: []Metric{{Name: "cpu", Type: CPU, Operator: Average, Value: float64(i)}},
+			}
+		}
+		var buf strings.Builder
+		require.Nil(t, encodeWatcherMetrics(&buf, metrics))
+		decoded := &WatcherMetrics{Data: Data{NodeMetricsMap: make(NodeMetricsMap)}}
+		require.Nil(t, gojay.UnmarshalJSONObject([]byte(buf.String()), decoded), buf.String())
+		assert.Equal(t, metrics, decoded)
+	}
+}
+
 func TestWatcherMetricsNotFound(t *testing.T) {
 	uri, _ := url.Parse(BaseUrl)
 	q := uri.Query()
@@ -108,7 +530,7 @@ func TestWatcherMetricsNotFound(t *testing.T) {
 
 func TestWatcherInternalServerError(t *testing.T) {
 	client := NewTestMetricsServerClient()
-	unstartedWatcher := NewWatcher(client)
+	unstartedWatcher := NewWatcher(client, WatcherOpts{})
 
 	req, err := http.NewRequest("GET", BaseUrl, nil)
 	require.Nil(t, err)
@@ -131,11 +553,170 @@ func TestWatcherHealthCheck(t *testing.T) {
 	require.Equal(t, http.StatusOK, rr.Code)
 }
 
+// Counts the fetches made through a MetricsProviderClient
+type countingClient struct {
+	MetricsProviderClient
+	fetches int32
+}
+
+func (c *countingClient) FetchAllHostsMetrics(window *Window) (map[string][]Metric, error) {
+	atomic.AddInt32(&c.fetches, 1)
+	return c.MetricsProviderClient.FetchAllHostsMetrics(window)
+}
+
+func TestWatcherStartStop(t *testing.T) {
+	client := &countingClient{MetricsProviderClient: NewTestMetricsServerClient()}
+	lifecycleWatcher := NewWatcher(client, WatcherOpts{Windows: []time.Duration{5 * time.Minute}, Address: "127.0.0.1:0"})
+	lifecycleWatcher.fetchInterval = 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.Nil(t, lifecycleWatcher.Start(ctx))
+	require.Nil(t, lifecycleWatcher.Start(ctx))
+	_, err := lifecycleWatcher.GetLatestWatcherMetrics(FiveMinutes)
+	require.Nil(t, err)
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&client.fetches) > 2
+	}, time.Second, 10*time.Millisecond)
+
+	lifecycleWatcher.Stop()
+	fetches := atomic.LoadInt32(&client.fetches)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, fetches, atomic.LoadInt32(&client.fetches))
+	_, err = lifecycleWatcher.GetLatestWatcherMetrics(FiveMinutes)
+	assert.NotNil(t, err)
+
+	// Stopping twice is a no-op, and a stopped Watcher can be started again
+	lifecycleWatcher.Stop()
+	require.Nil(t, lifecycleWatcher.Start(ctx))
+	_, err = lifecycleWatcher.GetLatestWatcherMetrics(FiveMinutes)
+	assert.Nil(t, err)
+
+	// Cancelling the context stops fetching and serving without calling Stop
+	cancel()
+	assert.Eventually(t, func() bool {
+		_, err := lifecycleWatcher.GetLatestWatcherMetrics(FiveMinutes)
+		return err != nil
+	}, time.Second, 10*time.Millisecond)
+	fetches = atomic.LoadInt32(&client.fetches)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, fetches, atomic.LoadInt32(&client.fetches))
+	assert.False(t, lifecycleWatcher.IsReady())
+
+	// A Watcher stopped by its context can be started again
+	require.Nil(t, lifecycleWatcher.Start(context.Background()))
+	_, err = lifecycleWatcher.GetLatestWatcherMetrics(FiveMinutes)
+	assert.Nil(t, err)
+	assert.True(t, lifecycleWatcher.IsReady())
+
+	// A shutdown signal stops the Watcher likewise
+	lifecycleWatcher.shutdown <- syscall.SIGTERM
+	assert.Eventually(t, func() bool {
+		return !lifecycleWatcher.IsReady()
+	}, time.Second, 10*time.Millisecond)
+	require.Nil(t, lifecycleWatcher.Start(context.Background()))
+	assert.True(t, lifecycleWatcher.IsReady())
+	lifecycleWatcher.Stop()
+	assert.False(t, lifecycleWatcher.IsReady())
+}
+
+// Blocks fetches until their context is done
+type blockingClient struct {
+	MetricsProviderClient
+}
+
+func (c blockingClient) FetchHostMetricsContext(ctx context.Context, host string, window *Window) ([]Metric, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (c blockingClient) FetchAllHostsMetricsContext(ctx context.Context, window *Window) (map[string][]Metric, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (c blockingClient) HealthContext(ctx context.Context) (int, error) {
+	return c.Health()
+}
+
+func TestWithContext(t *testing.T) {
+	client := &countingClient{MetricsProviderClient: NewTestMetricsServerClient()}
+	adapter := WithContext(client)
+	assert.Equal(t, adapter, WithContext(adapter))
+	assert.False(t, adapter.SupportsPodMetrics())
+
+	metrics, err := adapter.FetchAllHostsMetricsContext(context.Background(), CurrentFifteenMinuteWindow())
+	require.Nil(t, err)
+	assert.NotEmpty(t, metrics)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&client.fetches))
+
+	// Clients without context aware methods are not called once the context is done
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = adapter.FetchAllHostsMetricsContext(ctx, CurrentFifteenMinuteWindow())
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&client.fetches))
+
+	// Context aware methods are used when implemented
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = WithContext(blockingClient{MetricsProviderClient: client}).FetchAllHostsMetrics(CurrentFifteenMinuteWindow())
+	require.Nil(t, err)
+	_, err = WithContext(blockingClient{MetricsProviderClient: client}).FetchAllHostsMetricsContext(ctx, CurrentFifteenMinuteWindow())
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestWatcherFetchDeadline(t *testing.T) {
+	deadlineWatcher := NewWatcher(blockingClient{MetricsProviderClient: NewTestMetricsServerClient()}, WatcherOpts{})
+	deadlineWatcher.isStarted = true
+	deadlineWatcher.fetchInterval = 10 * time.Millisecond
+
+	// Fetches taking longer than the fetch interval are canceled
+	done := make(chan struct{})
+	go func() {
+		deadlineWatcher.fetchOnce(context.Background(), 15*time.Minute)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("fetch was not canceled at the fetch interval")
+	}
+	status := deadlineWatcher.WindowStatuses()[0]
+	assert.Equal(t, 1, status.ConsecutiveFailures)
+	assert.ErrorIs(t, status.LastError, context.DeadlineExceeded)
+}
+
+func TestWatcherHandlerMounted(t *testing.T) {
+	server := httptest.NewServer(w.Handler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + BaseUrl)
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestWatcherAddressInUse(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer listener.Close()
+
+	client := &countingClient{MetricsProviderClient: NewTestMetricsServerClient()}
+	conflictingWatcher := NewWatcher(client, WatcherOpts{Address: listener.Addr().String()})
+	assert.NotNil(t, conflictingWatcher.Start(context.Background()))
+	_, err = conflictingWatcher.GetLatestWatcherMetrics(FifteenMinutes)
+	assert.NotNil(t, err)
+}
+
 func TestMain(m *testing.M) {
 	client := NewTestMetricsServerClient()
-	w = NewWatcher(client)
-	w.StartWatching()
+	w = NewWatcher(client, WatcherOpts{Address: "127.0.0.1:0"})
+	if err := w.Start(context.Background()); err != nil {
+		panic(err)
+	}
 
 	ret := m.Run()
+	w.Stop()
 	os.Exit(ret)
 }